@@ -43,22 +43,25 @@ const AthenaInfoUpdateType = "athenainfo"
 type AWS struct {
 	Pricing                 map[string]*AWSProductTerms
 	SpotPricingByInstanceID map[string]*spotInfo
-	ValidPricingKeys        map[string]bool
-	Clientset               *kubernetes.Clientset
-	BaseCPUPrice            string
-	BaseRAMPrice            string
-	BaseGPUPrice            string
-	BaseSpotCPUPrice        string
-	BaseSpotRAMPrice        string
-	SpotLabelName           string
-	SpotLabelValue          string
-	ServiceKeyName          string
-	ServiceKeySecret        string
-	SpotDataRegion          string
-	SpotDataBucket          string
-	SpotDataPrefix          string
-	ProjectID               string
-	DownloadPricingDataLock sync.RWMutex
+	// ReservedPricingByInstanceID holds the amortized hourly rate of instances covered by a Reserved Instance or a
+	// Savings Plan
+	ReservedPricingByInstanceID map[string]string
+	ValidPricingKeys            map[string]bool
+	Clientset                   *kubernetes.Clientset
+	BaseCPUPrice                string
+	BaseRAMPrice                string
+	BaseGPUPrice                string
+	BaseSpotCPUPrice            string
+	BaseSpotRAMPrice            string
+	SpotLabelName               string
+	SpotLabelValue              string
+	ServiceKeyName              string
+	ServiceKeySecret            string
+	SpotDataRegion              string
+	SpotDataBucket              string
+	SpotDataPrefix              string
+	ProjectID                   string
+	DownloadPricingDataLock     sync.RWMutex
 	*CustomProvider
 }
 
@@ -452,6 +455,9 @@ func (aws *AWS) isPreemptible(key string) bool {
 
 // DownloadPricingData fetches data from the AWS Pricing API
 func (aws *AWS) DownloadPricingData() error {
+	// The CUR query can take minutes, so run it before taking the lock that pricing lookups wait on.
+	cur := aws.downloadReservedPricingFromCUR()
+
 	aws.DownloadPricingDataLock.Lock()
 	defer aws.DownloadPricingDataLock.Unlock()
 	c, err := GetDefaultPricingData("aws.json")
@@ -667,6 +673,12 @@ func (aws *AWS) DownloadPricingData() error {
 		aws.SpotPricingByInstanceID = sp
 	}
 
+	reserved := aws.reservedPricing(nodeList.Items, parseAWSReservedInstances(c.AwsReservedInstances), parseAWSSavingsPlans(c.AwsSavingsPlans))
+	for id, rate := range cur {
+		reserved[id] = rate
+	}
+	aws.ReservedPricingByInstanceID = reserved
+
 	return nil
 }
 
@@ -740,6 +752,19 @@ func (aws *AWS) createNode(terms *AWSProductTerms, usageType string, k Key) (*No
 			UsageType:    usageType,
 		}, nil
 	}
+	if cost, ok := aws.ReservedPricingByInstanceID[k.ID()]; ok {
+		return &Node{
+			Cost:         cost,
+			VCPU:         terms.VCpu,
+			RAM:          terms.Memory,
+			GPU:          terms.GPU,
+			Storage:      terms.Storage,
+			BaseCPUPrice: aws.BaseCPUPrice,
+			BaseRAMPrice: aws.BaseRAMPrice,
+			BaseGPUPrice: aws.BaseGPUPrice,
+			UsageType:    ReservedUsageType,
+		}, nil
+	}
 	c, ok := terms.OnDemand.PriceDimensions[terms.Sku+OnDemandRateCode+HourlyRateCode]
 	if !ok {
 		return nil, fmt.Errorf("Could not fetch data for \"%s\"", k.ID())
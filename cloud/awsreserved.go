@@ -0,0 +1,239 @@
+package cloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/athena"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// ReservedUsageType is the UsageType of nodes priced at the amortized rate of a Reserved Instance or Savings Plan.
+const ReservedUsageType = "reserved"
+
+// awsReservedCURDays is how many days of CUR data effective rates are averaged over. CUR data lags by up to a day.
+const awsReservedCURDays = 3
+
+// awsReservedInstance is a number of Reserved Instances of one type in one region, at an amortized hourly rate.
+type awsReservedInstance struct {
+	InstanceType string
+	Region       string
+	Count        int
+	HourlyRate   float64
+}
+
+// awsSavingsPlan is an hourly spend commitment discounting on-demand rates. Family and Region are "*" for a Compute
+// Savings Plan.
+type awsSavingsPlan struct {
+	Family           string
+	Region           string
+	HourlyCommitment float64
+	Discount         float64
+}
+
+// parseAWSReservedInstances reads a comma separated list of "instanceType:region:count:hourlyRate" entries, such as
+// "m5.large:us-east-1:4:0.062", where the rate is amortized over the term, upfront payment included.
+func parseAWSReservedInstances(ris string) []*awsReservedInstance {
+	var result []*awsReservedInstance
+	for _, ri := range strings.Split(ris, ",") {
+		if strings.TrimSpace(ri) == "" {
+			continue
+		}
+		fields := strings.Split(strings.TrimSpace(ri), ":")
+		if len(fields) != 4 {
+			klog.V(1).Infof("Ignoring invalid reserved instance %s", ri)
+			continue
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil || count < 0 {
+			klog.V(1).Infof("Ignoring invalid reserved instance %s", ri)
+			continue
+		}
+		rate, err := strconv.ParseFloat(fields[3], 64)
+		if err != nil || rate < 0 {
+			klog.V(1).Infof("Ignoring invalid reserved instance %s", ri)
+			continue
+		}
+		result = append(result, &awsReservedInstance{
+			InstanceType: fields[0],
+			Region:       fields[1],
+			Count:        count,
+			HourlyRate:   rate,
+		})
+	}
+	return result
+}
+
+// parseAWSSavingsPlans reads a comma separated list of "family:region:hourlyCommitment:discount" entries, such as
+// "m5:us-east-1:1.5:28%" for an EC2 Instance Savings Plan or "*:*:2:20%" for a Compute Savings Plan.
+func parseAWSSavingsPlans(sps string) []*awsSavingsPlan {
+	var result []*awsSavingsPlan
+	for _, sp := range strings.Split(sps, ",") {
+		if strings.TrimSpace(sp) == "" {
+			continue
+		}
+		fields := strings.Split(strings.TrimSpace(sp), ":")
+		if len(fields) != 4 {
+			klog.V(1).Infof("Ignoring invalid savings plan %s", sp)
+			continue
+		}
+		commitment, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || commitment < 0 {
+			klog.V(1).Infof("Ignoring invalid savings plan %s", sp)
+			continue
+		}
+		discount, err := strconv.ParseFloat(strings.TrimSuffix(fields[3], "%"), 64)
+		if err != nil || discount < 0 || discount >= 100 {
+			klog.V(1).Infof("Ignoring invalid savings plan %s", sp)
+			continue
+		}
+		result = append(result, &awsSavingsPlan{
+			Family:           fields[0],
+			Region:           fields[1],
+			HourlyCommitment: commitment,
+			Discount:         discount * 0.01,
+		})
+	}
+	return result
+}
+
+func (sp *awsSavingsPlan) matches(instanceType string, region string) bool {
+	family := strings.Split(instanceType, ".")[0]
+	return (sp.Family == "*" || sp.Family == family) && (sp.Region == "*" || sp.Region == region)
+}
+
+// reservedPricing amortizes the configured Reserved Instances and Savings Plans over the on-demand nodes, by instance
+// ID. Reserved Instances apply first, one node each. Savings Plans then cover the remaining nodes at their discounted
+// rate until the hourly commitment is spent, the node on the boundary being partially covered. Nodes are covered in
+// name order so the assignment is stable between downloads.
+func (aws *AWS) reservedPricing(nodes []v1.Node, ris []*awsReservedInstance, sps []*awsSavingsPlan) map[string]string {
+	result := make(map[string]string)
+	if len(ris) == 0 && len(sps) == 0 {
+		return result
+	}
+	nodes = append([]v1.Node(nil), nodes...)
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	remaining := make([]float64, len(sps))
+	for i, sp := range sps {
+		remaining[i] = sp.HourlyCommitment
+	}
+
+	for _, n := range nodes {
+		labels := make(map[string]string)
+		for k, v := range n.GetObjectMeta().GetLabels() {
+			labels[k] = v
+		}
+		labels["providerID"] = n.Spec.ProviderID
+		key := aws.GetKey(labels)
+		if key.ID() == "" || aws.isPreemptible(key.Features()) {
+			continue
+		}
+		instanceType := labels[v1.LabelInstanceType]
+		region := labels[v1.LabelZoneRegion]
+
+		reserved := false
+		for _, ri := range ris {
+			if ri.Count > 0 && ri.InstanceType == instanceType && ri.Region == region {
+				ri.Count--
+				result[key.ID()] = strconv.FormatFloat(ri.HourlyRate, 'f', -1, 64)
+				reserved = true
+				break
+			}
+		}
+		if reserved {
+			continue
+		}
+
+		terms, ok := aws.Pricing[key.Features()]
+		if !ok || terms.OnDemand == nil {
+			continue
+		}
+		c, ok := terms.OnDemand.PriceDimensions[terms.Sku+OnDemandRateCode+HourlyRateCode]
+		if !ok {
+			continue
+		}
+		onDemand, err := strconv.ParseFloat(c.PricePerUnit.USD, 64)
+		if err != nil || onDemand == 0 {
+			continue
+		}
+		for i, sp := range sps {
+			if remaining[i] <= 0 || !sp.matches(instanceType, region) {
+				continue
+			}
+			discounted := onDemand * (1 - sp.Discount)
+			covered := math.Min(discounted, remaining[i])
+			remaining[i] -= covered
+			rate := covered + (1-covered/discounted)*onDemand
+			result[key.ID()] = strconv.FormatFloat(rate, 'f', -1, 64)
+			break
+		}
+	}
+	return result
+}
+
+// downloadReservedPricingFromCUR returns the effective rates of reserved instances from the CUR when
+// awsReservedPricingFromCUR is enabled, or nil when it is not or the query fails.
+func (aws *AWS) downloadReservedPricingFromCUR() map[string]string {
+	c, err := aws.GetConfig()
+	if err != nil || c.AwsReservedPricingFromCUR != "true" {
+		return nil
+	}
+	cur, err := aws.reservedPricingFromCUR(c)
+	if err != nil {
+		klog.V(1).Infof("Skipping AWS reserved pricing from CUR: %s", err.Error())
+		return nil
+	}
+	return cur
+}
+
+// reservedPricingFromCUR queries the Cost and Usage Report through Athena for the effective hourly rate of each EC2
+// instance covered, at least partly, by a Reserved Instance or Savings Plan over the last days. The rate blends the
+// amortized cost of covered hours with the on-demand cost of the others. The CUR table must include the
+// savings_plan columns, which AWS adds once the account has a Savings Plan.
+func (aws *AWS) reservedPricingFromCUR(c *CustomPricing) (map[string]string, error) {
+	start := time.Now().UTC().AddDate(0, 0, -awsReservedCURDays).Format("2006-01-02")
+	query := fmt.Sprintf(`SELECT
+		line_item_resource_id,
+		SUM(CASE line_item_line_item_type
+			WHEN 'DiscountedUsage' THEN reservation_effective_cost
+			WHEN 'SavingsPlanCoveredUsage' THEN savings_plan_savings_plan_effective_cost
+			ELSE line_item_unblended_cost END) / SUM(line_item_usage_amount) as effective_rate
+	FROM %s as cost_data
+	WHERE line_item_usage_start_date >= date '%s'
+		AND line_item_product_code = 'AmazonEC2'
+		AND line_item_usage_type LIKE '%%BoxUsage%%'
+		AND line_item_line_item_type IN ('Usage', 'DiscountedUsage', 'SavingsPlanCoveredUsage')
+	GROUP BY 1
+	HAVING SUM(CASE WHEN line_item_line_item_type = 'Usage' THEN 0 ELSE line_item_usage_amount END) > 0`, c.AthenaTable, start)
+
+	b, err := aws.QuerySQL(query)
+	if err != nil {
+		return nil, err
+	}
+	var rs athena.ResultSet
+	err = json.Unmarshal(b, &rs)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for i, r := range rs.Rows {
+		if i == 0 || len(r.Data) < 2 || r.Data[0].VarCharValue == nil || r.Data[1].VarCharValue == nil { // the first row holds column names
+			continue
+		}
+		rate, err := strconv.ParseFloat(*r.Data[1].VarCharValue, 64)
+		if err != nil {
+			klog.V(3).Infof("Invalid effective rate for instance %s: %s", *r.Data[0].VarCharValue, *r.Data[1].VarCharValue)
+			continue
+		}
+		result[*r.Data[0].VarCharValue] = strconv.FormatFloat(rate, 'f', -1, 64)
+	}
+	return result, nil
+}
@@ -0,0 +1,194 @@
+package cloud
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testAWSNode(name string, instanceType string, region string) v1.Node {
+	return v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				v1.LabelInstanceType: instanceType,
+				v1.LabelZoneRegion:   region,
+				v1.LabelOSStable:     "linux",
+			},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "aws:///" + region + "a/i-" + name,
+		},
+	}
+}
+
+// testAWS returns a provider pricing every instance type of the nodes on demand at 0.1 an hour.
+func testAWS(nodes []v1.Node) *AWS {
+	aws := &AWS{
+		Pricing: make(map[string]*AWSProductTerms),
+	}
+	for _, n := range nodes {
+		key := n.Labels[v1.LabelZoneRegion] + "," + n.Labels[v1.LabelInstanceType] + ",linux"
+		aws.Pricing[key] = &AWSProductTerms{
+			Sku: "SKU",
+			OnDemand: &AWSOfferTerm{
+				Sku: "SKU",
+				PriceDimensions: map[string]*AWSRateCode{
+					"SKU" + OnDemandRateCode + HourlyRateCode: {
+						Unit:         "Hrs",
+						PricePerUnit: AWSCurrencyCode{USD: "0.1"},
+					},
+				},
+			},
+			VCpu:   "2",
+			Memory: "8 GiB",
+		}
+	}
+	return aws
+}
+
+func TestParseAWSReservedInstances(t *testing.T) {
+	ris := parseAWSReservedInstances("m5.large:us-east-1:4:0.062, c5.xlarge:eu-west-1:1:0.1, invalid, m5.large:us-east-1:-1:0.1, m5.large:us-east-1:1:free")
+	if len(ris) != 2 {
+		t.Fatalf("Parsed %d reserved instances, expected 2", len(ris))
+	}
+	if ri := ris[0]; ri.InstanceType != "m5.large" || ri.Region != "us-east-1" || ri.Count != 4 || ri.HourlyRate != 0.062 {
+		t.Errorf("Unexpected reserved instance %+v", *ri)
+	}
+	if ri := ris[1]; ri.InstanceType != "c5.xlarge" || ri.Region != "eu-west-1" || ri.Count != 1 || ri.HourlyRate != 0.1 {
+		t.Errorf("Unexpected reserved instance %+v", *ri)
+	}
+}
+
+func TestParseAWSSavingsPlans(t *testing.T) {
+	sps := parseAWSSavingsPlans("m5:us-east-1:1.5:28%, *:*:2:20, invalid, m5:us-east-1:1:100%, m5:us-east-1:-1:10%")
+	if len(sps) != 2 {
+		t.Fatalf("Parsed %d savings plans, expected 2", len(sps))
+	}
+	if sp := sps[0]; sp.Family != "m5" || sp.Region != "us-east-1" || sp.HourlyCommitment != 1.5 || math.Abs(sp.Discount-0.28) > 1e-9 {
+		t.Errorf("Unexpected savings plan %+v", *sp)
+	}
+	if sp := sps[1]; sp.Family != "*" || sp.Region != "*" || sp.HourlyCommitment != 2 || math.Abs(sp.Discount-0.2) > 1e-9 {
+		t.Errorf("Unexpected savings plan %+v", *sp)
+	}
+}
+
+func TestReservedPricing(t *testing.T) {
+	spot := testAWSNode("e", "m5.large", "us-east-1")
+	spot.Labels["lifecycle"] = "EC2Spot"
+	unmanaged := testAWSNode("f", "m5.large", "us-east-1")
+	unmanaged.Spec.ProviderID = ""
+	// Nodes are covered in name order whatever their order in the list.
+	nodes := []v1.Node{
+		testAWSNode("b", "m5.large", "us-east-1"),
+		testAWSNode("a", "m5.large", "us-east-1"),
+		testAWSNode("c", "m5.large", "us-west-2"),
+		testAWSNode("d", "c5.large", "us-east-1"),
+		spot,
+		unmanaged,
+	}
+
+	cases := []struct {
+		name     string
+		ris      string
+		sps      string
+		expected map[string]float64
+	}{
+		{
+			name:     "none",
+			expected: map[string]float64{},
+		},
+		{
+			name:     "reserved instance by type and region",
+			ris:      "m5.large:us-east-1:1:0.06,m5.large:us-west-2:2:0.07,c5.large:us-west-2:1:0.05",
+			expected: map[string]float64{"i-a": 0.06, "i-c": 0.07},
+		},
+		{
+			// The commitment covers a's discounted rate of 0.08 and 0.07 of b's, the rest of b running on demand.
+			name:     "instance savings plan",
+			sps:      "m5:us-east-1:0.15:20%",
+			expected: map[string]float64{"i-a": 0.08, "i-b": 0.07 + 0.1/8},
+		},
+		{
+			name:     "compute savings plan",
+			sps:      "*:*:1:20%",
+			expected: map[string]float64{"i-a": 0.08, "i-b": 0.08, "i-c": 0.08, "i-d": 0.08},
+		},
+		{
+			name:     "savings plan family wildcard",
+			sps:      "*:us-east-1:1:20%",
+			expected: map[string]float64{"i-a": 0.08, "i-b": 0.08, "i-d": 0.08},
+		},
+		{
+			name:     "savings plan region wildcard",
+			sps:      "m5:*:1:20%",
+			expected: map[string]float64{"i-a": 0.08, "i-b": 0.08, "i-c": 0.08},
+		},
+		{
+			name:     "reserved instances before savings plans",
+			ris:      "m5.large:us-east-1:1:0.06",
+			sps:      "m5:us-east-1:0.08:20%",
+			expected: map[string]float64{"i-a": 0.06, "i-b": 0.08},
+		},
+		{
+			name:     "savings plan spent",
+			sps:      "m5:us-east-1:0:20%",
+			expected: map[string]float64{},
+		},
+	}
+	aws := testAWS(nodes)
+	for _, c := range cases {
+		rates := aws.reservedPricing(nodes, parseAWSReservedInstances(c.ris), parseAWSSavingsPlans(c.sps))
+		if len(rates) != len(c.expected) {
+			t.Errorf("%s: got rates %v, expected %v", c.name, rates, c.expected)
+		}
+		for id, expected := range c.expected {
+			rate, err := strconv.ParseFloat(rates[id], 64)
+			if err != nil || math.Abs(rate-expected) > 1e-9 {
+				t.Errorf("%s: rate of %s is %s, expected %f", c.name, id, rates[id], expected)
+			}
+		}
+	}
+	if nodes[0].Name != "b" || nodes[1].Name != "a" {
+		t.Errorf("The nodes passed in were reordered")
+	}
+}
+
+func TestNodePricingReserved(t *testing.T) {
+	reserved := testAWSNode("a", "m5.large", "us-east-1")
+	onDemand := testAWSNode("b", "m5.large", "us-east-1")
+	spot := testAWSNode("c", "m5.large", "us-east-1")
+	spot.Labels["lifecycle"] = "EC2Spot"
+	aws := testAWS([]v1.Node{reserved})
+	aws.ReservedPricingByInstanceID = map[string]string{"i-a": "0.06", "i-c": "0.06"}
+
+	cases := []struct {
+		node      v1.Node
+		cost      string
+		usageType string
+	}{
+		{reserved, "0.06", ReservedUsageType},
+		{onDemand, "0.1", "ondemand"},
+		{spot, "", "preemptible"},
+	}
+	for _, c := range cases {
+		labels := map[string]string{"providerID": c.node.Spec.ProviderID}
+		for k, v := range c.node.Labels {
+			labels[k] = v
+		}
+		if c.usageType == "preemptible" {
+			aws.Pricing[aws.GetKey(labels).Features()] = aws.Pricing[aws.GetKey(reserved.Labels).Features()]
+		}
+		n, err := aws.NodePricing(aws.GetKey(labels))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.node.Name, err.Error())
+			continue
+		}
+		if n.Cost != c.cost || n.UsageType != c.usageType {
+			t.Errorf("%s: priced at %q as %s, expected %q as %s", c.node.Name, n.Cost, n.UsageType, c.cost, c.usageType)
+		}
+	}
+}
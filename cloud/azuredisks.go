@@ -0,0 +1,140 @@
+package cloud
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	azureStandardHDD = "standardhdd"
+	azureStandardSSD = "standardssd"
+	azurePremiumSSD  = "premiumssd"
+)
+
+// azureDiskMeter matches the names of the monthly managed disk meters, e.g. "P10 Disks" or "E10 LRS Disk".
+var azureDiskMeter = regexp.MustCompile(`^([PES]\d+) (LRS )?Disks?$`)
+
+// azureDiskTierSizes are the provisioned sizes, in GiB, of the managed disk tiers.
+var azureDiskTierSizes = map[string]float64{
+	"1": 4, "2": 8, "3": 16, "4": 32, "6": 64, "10": 128, "15": 256, "20": 512,
+	"30": 1024, "40": 2048, "50": 4096, "60": 8192, "70": 16384, "80": 32767,
+}
+
+// azureDiskTypes maps the disk SKUs of StorageClass parameters, and the tier letters of meters, to disk types.
+var azureDiskTypes = map[string]string{
+	"standard_lrs":    azureStandardHDD,
+	"standardssd_lrs": azureStandardSSD,
+	"premium_lrs":     azurePremiumSSD,
+	"S":               azureStandardHDD,
+	"E":               azureStandardSSD,
+	"P":               azurePremiumSSD,
+}
+
+// azurePVRegion returns the region of a persistent volume from its region label or, as CSI volumes have none, from
+// the topology its node affinity requires. Zones of Azure disks are named after their region, e.g. eastus-1.
+func azurePVRegion(pv *v1.PersistentVolume) string {
+	if region, ok := pv.Labels[v1.LabelZoneRegion]; ok {
+		return region
+	}
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return ""
+	}
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Operator != v1.NodeSelectorOpIn || len(expr.Values) == 0 {
+				continue
+			}
+			switch expr.Key {
+			case v1.LabelZoneRegion, "topology.kubernetes.io/region":
+				return expr.Values[0]
+			case v1.LabelZoneFailureDomain, "topology.kubernetes.io/zone", "topology.disk.csi.azure.com/zone":
+				if i := strings.LastIndex(expr.Values[0], "-"); i > 0 {
+					return expr.Values[0][:i]
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// azureDiskTier is a managed disk size billed at a monthly price, whatever the part of it used.
+type azureDiskTier struct {
+	Tier         string  `json:"tier"`
+	SizeGB       float64 `json:"sizeGB"`
+	MonthlyPrice float64 `json:"monthlyPrice"`
+}
+
+// azureDiskSubCategory reports whether a meter sub category is the one of locally redundant managed disks.
+func azureDiskSubCategory(sc string) bool {
+	return strings.Contains(sc, "Managed Disks") && !strings.Contains(sc, "ZRS")
+}
+
+// parseAzureDiskMeter returns the disk type and tier of a managed disk meter, or false if the meter is another one,
+// such as the one of disk operations.
+func parseAzureDiskMeter(meterName string) (string, *azureDiskTier, bool) {
+	m := azureDiskMeter.FindStringSubmatch(meterName)
+	if m == nil {
+		return "", nil, false
+	}
+	size, ok := azureDiskTierSizes[m[1][1:]]
+	if !ok {
+		return "", nil, false
+	}
+	return azureDiskTypes[m[1][:1]], &azureDiskTier{
+		Tier:   m[1],
+		SizeGB: size,
+	}, true
+}
+
+// addDiskTier adds the tier to those of its region and disk type, keeping them sorted by size.
+func addDiskTier(diskPrices map[string][]*azureDiskTier, key string, tier *azureDiskTier) {
+	for _, t := range diskPrices[key] {
+		if t.Tier == tier.Tier {
+			return
+		}
+	}
+	tiers := append(diskPrices[key], tier)
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].SizeGB < tiers[j].SizeGB
+	})
+	diskPrices[key] = tiers
+}
+
+// diskHourlyCost returns the hourly cost per GiB of a disk of the given size, billed at the price of the smallest tier
+// it fits in.
+func diskHourlyCost(tiers []*azureDiskTier, sizeGB float64) (float64, bool) {
+	if sizeGB <= 0 {
+		return 0, false
+	}
+	for _, t := range tiers {
+		if t.SizeGB >= sizeGB {
+			return t.MonthlyPrice / 730 / sizeGB, true
+		}
+	}
+	return 0, false
+}
+
+// PVPricing returns the cost of a managed disk persistent volume per GiB-hour.
+func (az *Azure) PVPricing(pvk PVKey) (*PV, error) {
+	az.DownloadPricingDataLock.RLock()
+	defer az.DownloadPricingDataLock.RUnlock()
+	key, ok := pvk.(*azurePvKey)
+	if !ok {
+		return nil, nil
+	}
+	cost, ok := diskHourlyCost(az.diskPrices[key.Features()], key.SizeGB)
+	if !ok {
+		klog.V(4).Infof("Persistent Volume pricing not found for %s: %s", pvk.GetStorageClass(), pvk.Features())
+		return &PV{}, nil
+	}
+	return &PV{
+		Cost:   strconv.FormatFloat(cost, 'f', -1, 64),
+		Class:  key.StorageClass,
+		Region: key.Region,
+	}, nil
+}
@@ -0,0 +1,151 @@
+package cloud
+
+import (
+	"math"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseAzureDiskMeter(t *testing.T) {
+	cases := []struct {
+		meter    string
+		diskType string
+		tier     string
+		sizeGB   float64
+		ok       bool
+	}{
+		{"P10 Disks", azurePremiumSSD, "P10", 128, true},
+		{"E10 LRS Disk", azureStandardSSD, "E10", 128, true},
+		{"S4 Disks", azureStandardHDD, "S4", 32, true},
+		{"P80 Disks", azurePremiumSSD, "P80", 32767, true},
+		{"P5 Disks", "", "", 0, false},
+		{"Disk Operations", "", "", 0, false},
+		{"P10 ZRS Disk", "", "", 0, false},
+	}
+	for _, c := range cases {
+		diskType, tier, ok := parseAzureDiskMeter(c.meter)
+		if ok != c.ok {
+			t.Errorf("Meter %q parsed %t, expected %t", c.meter, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if diskType != c.diskType || tier.Tier != c.tier || tier.SizeGB != c.sizeGB {
+			t.Errorf("Meter %q parsed as %s %s of %fGiB, expected %s %s of %fGiB", c.meter, diskType, tier.Tier, tier.SizeGB, c.diskType, c.tier, c.sizeGB)
+		}
+	}
+}
+
+func TestDiskHourlyCost(t *testing.T) {
+	diskPrices := make(map[string][]*azureDiskTier)
+	// Tiers are added out of order, and added only once.
+	addDiskTier(diskPrices, "eastus,premiumssd", &azureDiskTier{Tier: "P10", SizeGB: 128, MonthlyPrice: 730 * 128})
+	addDiskTier(diskPrices, "eastus,premiumssd", &azureDiskTier{Tier: "P4", SizeGB: 32, MonthlyPrice: 730 * 64})
+	addDiskTier(diskPrices, "eastus,premiumssd", &azureDiskTier{Tier: "P6", SizeGB: 64, MonthlyPrice: 730 * 96})
+	addDiskTier(diskPrices, "eastus,premiumssd", &azureDiskTier{Tier: "P4", SizeGB: 32, MonthlyPrice: 0})
+	tiers := diskPrices["eastus,premiumssd"]
+	if len(tiers) != 3 || tiers[0].Tier != "P4" || tiers[1].Tier != "P6" || tiers[2].Tier != "P10" {
+		t.Fatalf("Unexpected tiers %v", tiers)
+	}
+
+	cases := []struct {
+		sizeGB float64
+		cost   float64
+		ok     bool
+	}{
+		{1, 64, true},
+		{32, 2, true},
+		{33, 96.0 / 33, true},
+		{64, 1.5, true},
+		{100, 1.28, true},
+		{128, 1, true},
+		{129, 0, false},
+		{0, 0, false},
+	}
+	for _, c := range cases {
+		cost, ok := diskHourlyCost(tiers, c.sizeGB)
+		if ok != c.ok || math.Abs(cost-c.cost) > 1e-9 {
+			t.Errorf("Cost of a %fGiB disk is %f (%t), expected %f (%t)", c.sizeGB, cost, ok, c.cost, c.ok)
+		}
+	}
+}
+
+func testAzurePV(labels map[string]string, key string, value string) *v1.PersistentVolume {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pv",
+			Labels: labels,
+		},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{
+				v1.ResourceStorage: resource.MustParse("100Gi"),
+			},
+			StorageClassName: "managed-csi",
+		},
+	}
+	if key != "" {
+		pv.Spec.NodeAffinity = &v1.VolumeNodeAffinity{
+			Required: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{{
+					MatchExpressions: []v1.NodeSelectorRequirement{{
+						Key:      key,
+						Operator: v1.NodeSelectorOpIn,
+						Values:   []string{value},
+					}},
+				}},
+			},
+		}
+	}
+	return pv
+}
+
+func TestAzurePvKeyFeatures(t *testing.T) {
+	az := &Azure{}
+	cases := []struct {
+		name       string
+		pv         *v1.PersistentVolume
+		parameters map[string]string
+		features   string
+	}{
+		{
+			name:     "region label",
+			pv:       testAzurePV(map[string]string{v1.LabelZoneRegion: "EastUS"}, "topology.disk.csi.azure.com/zone", "westus-1"),
+			features: "eastus," + azureStandardHDD,
+		},
+		{
+			name:       "csi zone",
+			pv:         testAzurePV(nil, "topology.disk.csi.azure.com/zone", "eastus-2"),
+			parameters: map[string]string{"skuName": "Premium_LRS"},
+			features:   "eastus," + azurePremiumSSD,
+		},
+		{
+			name:       "region topology",
+			pv:         testAzurePV(nil, "topology.kubernetes.io/region", "westeurope"),
+			parameters: map[string]string{"storageaccounttype": "StandardSSD_LRS"},
+			features:   "westeurope," + azureStandardSSD,
+		},
+		{
+			name:     "non-zonal csi disk",
+			pv:       testAzurePV(nil, "topology.disk.csi.azure.com/zone", ""),
+			features: "," + azureStandardHDD,
+		},
+		{
+			name:     "no topology",
+			pv:       testAzurePV(nil, "", ""),
+			features: "," + azureStandardHDD,
+		},
+	}
+	for _, c := range cases {
+		key := az.GetPVKey(c.pv, c.parameters)
+		if features := key.Features(); features != c.features {
+			t.Errorf("%s: features are %q, expected %q", c.name, features, c.features)
+		}
+		if size := key.(*azurePvKey).SizeGB; size != 100 {
+			t.Errorf("%s: size is %fGiB, expected 100GiB", c.name, size)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package cloud
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// azureExportRange matches the folder of the files exported for a billing period, e.g. "20191001-20191031".
+var azureExportRange = regexp.MustCompile(`^(\d{8})-(\d{8})$`)
+
+// Columns of Cost Management exports, by order of preference. Their names differ between billing account types and
+// export schema versions.
+var (
+	azureExportDateColumns    = []string{"Date", "UsageDateTime", "UsageDate"}
+	azureExportCostColumns    = []string{"CostInBillingCurrency", "PreTaxCost", "Cost"}
+	azureExportServiceColumns = []string{"MeterCategory", "ConsumedService", "ServiceName"}
+	azureExportTagsColumns    = []string{"Tags"}
+)
+
+// azureBlobClient lists and downloads export blobs. Exports of large subscriptions run to hundreds of MB a month.
+var azureBlobClient = &http.Client{
+	Timeout: 5 * time.Minute,
+}
+
+// azureExportFile is a CSV file written by a Cost Management export, in a local directory or a blob container.
+type azureExportFile struct {
+	Name     string
+	Modified time.Time
+	open     func() (io.ReadCloser, error)
+}
+
+// azureBlobList is the response of the List Blobs operation of the Blob service REST API.
+type azureBlobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified string `xml:"Last-Modified"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// ExternalAllocations returns the cost of the resources outside the cluster tagged with the aggregator, read from the
+// Cost Management exports configured in azureBillingExportPath, a local file or directory, or
+// azureBillingExportContainer, the URL of a blob container with a SAS token allowing to list and read blobs.
+// "start" and "end" are dates of the format YYYY-MM-DD
+// "aggregator" is the tag used to determine how to allocate those assets, ie namespace, pod, etc. Resources may be
+// tagged either with the aggregator itself or, as on AWS and GCP, with kubernetes_ followed by the aggregator.
+func (az *Azure) ExternalAllocations(start string, end string, aggregator string) ([]*OutOfClusterAllocation, error) {
+	c, err := az.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if c.AzureBillingExportPath == "" && c.AzureBillingExportContainer == "" {
+		return nil, nil
+	}
+	s, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid start date %s, expected YYYY-MM-DD", start)
+	}
+	e, err := time.Parse("2006-01-02", end)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid end date %s, expected YYYY-MM-DD", end)
+	}
+
+	var files []*azureExportFile
+	if c.AzureBillingExportPath != "" {
+		files, err = localExportFiles(c.AzureBillingExportPath)
+	} else {
+		files, err = blobExportFiles(c.AzureBillingExportContainer, c.AzureBillingExportPrefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	costs := make(map[[2]string]float64)
+	for _, f := range latestExportFiles(files, s, e) {
+		klog.V(3).Infof("Reading Azure cost export %s", f.Name)
+		rc, err := f.open()
+		if err != nil {
+			return nil, err
+		}
+		err = addExportCosts(costs, rc, s, e, aggregator)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading Azure cost export %s: %s", f.Name, err.Error())
+		}
+	}
+
+	var oocAllocs []*OutOfClusterAllocation
+	for k, cost := range costs {
+		oocAllocs = append(oocAllocs, &OutOfClusterAllocation{
+			Aggregator:  aggregator,
+			Environment: k[0],
+			Service:     k[1],
+			Cost:        cost,
+		})
+	}
+	sort.Slice(oocAllocs, func(i, j int) bool {
+		if oocAllocs[i].Environment != oocAllocs[j].Environment {
+			return oocAllocs[i].Environment < oocAllocs[j].Environment
+		}
+		return oocAllocs[i].Service < oocAllocs[j].Service
+	})
+	return oocAllocs, nil
+}
+
+// latestExportFiles keeps the most recent file of each billing period folder overlapping [start, end], and every file
+// outside such folders. Exports of the month to date rewrite the whole period in a new file of the same folder each
+// run, so older files hold costs the latest one already includes.
+func latestExportFiles(files []*azureExportFile, start time.Time, end time.Time) []*azureExportFile {
+	var result []*azureExportFile
+	latest := make(map[string]*azureExportFile)
+	for _, f := range files {
+		if !strings.HasSuffix(strings.ToLower(f.Name), ".csv") {
+			continue
+		}
+		dir := path.Dir(filepath.ToSlash(f.Name))
+		m := azureExportRange.FindStringSubmatch(path.Base(dir))
+		if m == nil {
+			result = append(result, f)
+			continue
+		}
+		from, err1 := time.Parse("20060102", m[1])
+		to, err2 := time.Parse("20060102", m[2])
+		if err1 != nil || err2 != nil {
+			result = append(result, f)
+			continue
+		}
+		if to.Before(start) || from.After(end) {
+			continue
+		}
+		if l, ok := latest[dir]; !ok || f.Modified.After(l.Modified) {
+			latest[dir] = f
+		}
+	}
+	for _, f := range latest {
+		result = append(result, f)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// localExportFiles returns the export file at p, or those found under it if it is a directory.
+func localExportFiles(p string) ([]*azureExportFile, error) {
+	var files []*azureExportFile
+	err := filepath.Walk(p, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		files = append(files, &azureExportFile{
+			Name:     name,
+			Modified: info.ModTime(),
+			open: func() (io.ReadCloser, error) {
+				return os.Open(name)
+			},
+		})
+		return nil
+	})
+	return files, err
+}
+
+// blobExportFiles lists the blobs under prefix in the container. The container URL carries the SAS token as its query.
+func blobExportFiles(containerURL string, prefix string) ([]*azureExportFile, error) {
+	u, err := url.Parse(containerURL)
+	if err != nil {
+		return nil, err
+	}
+	var files []*azureExportFile
+	marker := ""
+	for {
+		listURL := *u
+		q := listURL.Query()
+		q.Set("restype", "container")
+		q.Set("comp", "list")
+		if prefix != "" {
+			q.Set("prefix", prefix)
+		}
+		if marker != "" {
+			q.Set("marker", marker)
+		}
+		listURL.RawQuery = q.Encode()
+
+		var list azureBlobList
+		if err := listBlobs(listURL.String(), &list); err != nil {
+			return nil, err
+		}
+		for _, b := range list.Blobs {
+			modified, err := time.Parse(time.RFC1123, b.Properties.LastModified)
+			if err != nil {
+				klog.V(3).Infof("Invalid last modified time %s of blob %s", b.Properties.LastModified, b.Name)
+			}
+			blobURL := *u
+			blobURL.Path = strings.TrimSuffix(u.Path, "/") + "/" + b.Name
+			blobURL.RawPath = ""
+			name := b.Name
+			files = append(files, &azureExportFile{
+				Name:     name,
+				Modified: modified,
+				open: func() (io.ReadCloser, error) {
+					resp, err := azureBlobClient.Get(blobURL.String())
+					if err != nil {
+						return nil, err
+					}
+					if resp.StatusCode != http.StatusOK {
+						resp.Body.Close()
+						return nil, fmt.Errorf("Blob %s returned status %d", name, resp.StatusCode)
+					}
+					return resp.Body, nil
+				},
+			})
+		}
+		if list.NextMarker == "" {
+			return files, nil
+		}
+		marker = list.NextMarker
+	}
+}
+
+func listBlobs(u string, list *azureBlobList) error {
+	resp, err := azureBlobClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Blob container listing returned status %d", resp.StatusCode)
+	}
+	return xml.NewDecoder(resp.Body).Decode(list)
+}
+
+// addExportCosts adds the cost of the rows of the export dated within [start, end] and tagged with the aggregator to
+// costs, keyed by tag value and service.
+func addExportCosts(costs map[[2]string]float64, r io.Reader, start time.Time, end time.Time, aggregator string) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	dateCol := exportColumn(header, azureExportDateColumns)
+	costCol := exportColumn(header, azureExportCostColumns)
+	serviceCol := exportColumn(header, azureExportServiceColumns)
+	tagsCol := exportColumn(header, azureExportTagsColumns)
+	if dateCol < 0 || costCol < 0 || serviceCol < 0 || tagsCol < 0 {
+		return fmt.Errorf("Missing date, cost, service or tags column")
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if len(record) != len(header) {
+			continue
+		}
+		value, ok := exportTagValue(record[tagsCol], aggregator)
+		if !ok {
+			continue
+		}
+		date, err := parseExportDate(record[dateCol])
+		if err != nil {
+			klog.V(3).Infof("Invalid date %s in Azure cost export", record[dateCol])
+			continue
+		}
+		if date.Before(start) || date.After(end) {
+			continue
+		}
+		cost, err := strconv.ParseFloat(record[costCol], 64)
+		if err != nil {
+			klog.V(3).Infof("Invalid cost %s in Azure cost export", record[costCol])
+			continue
+		}
+		costs[[2]string{value, record[serviceCol]}] += cost
+	}
+}
+
+// exportColumn returns the index of the first of the names found in the header, ignoring case, or -1.
+func exportColumn(header []string, names []string) int {
+	for _, name := range names {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseExportDate reads the day of a date formatted as MM/DD/YYYY or YYYY-MM-DD, optionally followed by a time.
+func parseExportDate(d string) (time.Time, error) {
+	if len(d) < 10 {
+		return time.Time{}, fmt.Errorf("Invalid date %s", d)
+	}
+	if t, err := time.Parse("01/02/2006", d[:10]); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", d[:10])
+}
+
+// exportTagValue returns the value of the tag named aggregator or kubernetes_<aggregator>, ignoring case as Azure does.
+// Exports write tags as a JSON object, with or without its braces.
+func exportTagValue(tags string, aggregator string) (string, bool) {
+	tags = strings.TrimSpace(tags)
+	if tags == "" || aggregator == "" {
+		return "", false
+	}
+	if !strings.HasPrefix(tags, "{") {
+		tags = "{" + tags + "}"
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(tags), &m); err != nil {
+		return "", false
+	}
+	for _, key := range []string{aggregator, "kubernetes_" + aggregator} {
+		for k, v := range m {
+			if s, ok := v.(string); ok && strings.EqualFold(k, key) && s != "" {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,101 @@
+package cloud
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportTagValue(t *testing.T) {
+	cases := []struct {
+		tags       string
+		aggregator string
+		value      string
+		ok         bool
+	}{
+		{`"namespace": "ns1"`, "namespace", "ns1", true},
+		{`{"namespace": "ns1"}`, "namespace", "ns1", true},
+		{`"Namespace": "ns1"`, "namespace", "ns1", true},
+		{`"kubernetes_namespace": "ns1"`, "namespace", "ns1", true},
+		{`"kubernetes_namespace": "ns2", "namespace": "ns1"`, "namespace", "ns1", true},
+		{`"namespace": ""`, "namespace", "", false},
+		{`"team": "a"`, "namespace", "", false},
+		{`"namespace": "ns1"`, "", "", false},
+		{"", "namespace", "", false},
+		{`"namespace": `, "namespace", "", false},
+	}
+	for _, c := range cases {
+		value, ok := exportTagValue(c.tags, c.aggregator)
+		if value != c.value || ok != c.ok {
+			t.Errorf("Tag %s of %q is %q (%t), expected %q (%t)", c.aggregator, c.tags, value, ok, c.value, c.ok)
+		}
+	}
+}
+
+func TestAddExportCosts(t *testing.T) {
+	f, err := os.Open("testdata/azure_export.csv")
+	if err != nil {
+		t.Fatalf("Unable to open the export fixture: %s", err.Error())
+	}
+	defer f.Close()
+
+	start := time.Date(2019, time.October, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, time.October, 3, 0, 0, 0, 0, time.UTC)
+	costs := map[[2]string]float64{
+		{"ns1", "Storage"}: 0.5,
+	}
+	if err := addExportCosts(costs, f, start, end, "namespace"); err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	// Rows out of the range, without the tag or with an invalid cost are skipped.
+	expected := map[[2]string]float64{
+		{"ns1", "Storage"}:          0.5 + 1.5 + 2 + 0.25,
+		{"ns2", "Virtual Machines"}: 4,
+	}
+	if len(costs) != len(expected) {
+		t.Errorf("Got costs %v, expected %v", costs, expected)
+	}
+	for k, cost := range expected {
+		if costs[k] != cost {
+			t.Errorf("Cost of %v is %f, expected %f", k, costs[k], cost)
+		}
+	}
+
+	if err := addExportCosts(costs, strings.NewReader("Date,Cost,Tags\n"), start, end, "namespace"); err == nil {
+		t.Errorf("Expected an error for an export without service column")
+	}
+	if err := addExportCosts(costs, strings.NewReader(""), start, end, "namespace"); err != nil {
+		t.Errorf("Unexpected error for an empty export: %s", err.Error())
+	}
+}
+
+func TestLatestExportFiles(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2019, time.October, d, 0, 0, 0, 0, time.UTC)
+	}
+	files := []*azureExportFile{
+		{Name: "exports/daily/20191001-20191031/export_1.csv", Modified: day(2)},
+		{Name: "exports/daily/20191001-20191031/export_2.csv", Modified: day(3)},
+		{Name: "exports/daily/20190901-20190930/export_1.csv", Modified: day(1)},
+		{Name: "exports/manual/september.csv", Modified: day(1)},
+		{Name: "exports/manual/october.csv", Modified: day(2)},
+		{Name: "exports/manual/20191001-20191031-backup/export.csv", Modified: day(2)},
+		{Name: "exports/daily/20191001-20191031/manifest.json", Modified: day(4)},
+	}
+	latest := latestExportFiles(files, day(5), day(10))
+	expected := []string{
+		"exports/daily/20191001-20191031/export_2.csv",
+		"exports/manual/20191001-20191031-backup/export.csv",
+		"exports/manual/october.csv",
+		"exports/manual/september.csv",
+	}
+	if len(latest) != len(expected) {
+		t.Fatalf("Got %d files, expected %d", len(latest), len(expected))
+	}
+	for i, f := range latest {
+		if f.Name != expected[i] {
+			t.Errorf("File %d is %s, expected %s", i, f.Name, expected[i])
+		}
+	}
+}
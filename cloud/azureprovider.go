@@ -55,6 +55,50 @@ var (
 	mtStandardN, _ = regexp.Compile(`^Standard_N[C|D|V]\d+r?[_v\d]*[_Promo]*$`)
 )
 
+const (
+	azureSpotLabel = "kubernetes.azure.com/scalesetpriority"
+	azureSpotValue = "spot"
+)
+
+// azureGPUSku is the GPU model and count, vCPUs and GB of RAM of a GPU VM size.
+type azureGPUSku struct {
+	GPUName string
+	GPU     int
+	VCPU    float64
+	RAMGB   float64
+}
+
+// azureGPUSkus maps the N-series VM sizes to their GPUs, since rate card meters only price the whole VM.
+var azureGPUSkus = map[string]*azureGPUSku{
+	"Standard_NC6":          {"nvidia-tesla-k80", 1, 6, 56},
+	"Standard_NC12":         {"nvidia-tesla-k80", 2, 12, 112},
+	"Standard_NC24":         {"nvidia-tesla-k80", 4, 24, 224},
+	"Standard_NC24r":        {"nvidia-tesla-k80", 4, 24, 224},
+	"Standard_NC6s_v2":      {"nvidia-tesla-p100", 1, 6, 112},
+	"Standard_NC12s_v2":     {"nvidia-tesla-p100", 2, 12, 224},
+	"Standard_NC24s_v2":     {"nvidia-tesla-p100", 4, 24, 448},
+	"Standard_NC24rs_v2":    {"nvidia-tesla-p100", 4, 24, 448},
+	"Standard_NC6s_v3":      {"nvidia-tesla-v100", 1, 6, 112},
+	"Standard_NC12s_v3":     {"nvidia-tesla-v100", 2, 12, 224},
+	"Standard_NC24s_v3":     {"nvidia-tesla-v100", 4, 24, 448},
+	"Standard_NC24rs_v3":    {"nvidia-tesla-v100", 4, 24, 448},
+	"Standard_NC4as_T4_v3":  {"nvidia-tesla-t4", 1, 4, 28},
+	"Standard_NC8as_T4_v3":  {"nvidia-tesla-t4", 1, 8, 56},
+	"Standard_NC16as_T4_v3": {"nvidia-tesla-t4", 1, 16, 110},
+	"Standard_NC64as_T4_v3": {"nvidia-tesla-t4", 4, 64, 440},
+	"Standard_ND6s":         {"nvidia-tesla-p40", 1, 6, 112},
+	"Standard_ND12s":        {"nvidia-tesla-p40", 2, 12, 224},
+	"Standard_ND24s":        {"nvidia-tesla-p40", 4, 24, 448},
+	"Standard_ND24rs":       {"nvidia-tesla-p40", 4, 24, 448},
+	"Standard_ND40rs_v2":    {"nvidia-tesla-v100", 8, 40, 672},
+	"Standard_NV6":          {"nvidia-tesla-m60", 1, 6, 56},
+	"Standard_NV12":         {"nvidia-tesla-m60", 2, 12, 112},
+	"Standard_NV24":         {"nvidia-tesla-m60", 4, 24, 224},
+	"Standard_NV12s_v3":     {"nvidia-tesla-m60", 1, 12, 112},
+	"Standard_NV24s_v3":     {"nvidia-tesla-m60", 2, 24, 224},
+	"Standard_NV48s_v3":     {"nvidia-tesla-m60", 4, 48, 448},
+}
+
 type regionParts []string
 
 func (r regionParts) String() string {
@@ -164,6 +208,7 @@ func checkRegionID(regionID string, regions map[string]string) bool {
 
 type Azure struct {
 	allPrices               map[string]*Node
+	diskPrices              map[string][]*azureDiskTier
 	DownloadPricingDataLock sync.RWMutex
 	Clientset               *kubernetes.Clientset
 }
@@ -176,10 +221,23 @@ func (k *azureKey) Features() string {
 	region := strings.ToLower(k.Labels[v1.LabelZoneRegion])
 	instance := k.Labels[v1.LabelInstanceType]
 	usageType := "ondemand"
+	if k.Labels[azureSpotLabel] == azureSpotValue {
+		usageType = "preemptible"
+	}
+	operatingSystem, ok := k.Labels[v1.LabelOSStable]
+	if !ok {
+		operatingSystem = k.Labels["beta.kubernetes.io/os"]
+	}
+	if strings.ToLower(operatingSystem) == "windows" {
+		return fmt.Sprintf("%s,%s,%s,windows", region, instance, usageType)
+	}
 	return fmt.Sprintf("%s,%s,%s", region, instance, usageType)
 }
 
 func (k *azureKey) GPUType() string {
+	if sku, ok := azureGPUSkus[k.Labels[v1.LabelInstanceType]]; ok {
+		return sku.GPUName
+	}
 	return ""
 }
 
@@ -331,7 +389,6 @@ func (az *Azure) DownloadPricingData() error {
 	if err != nil {
 		return err
 	}
-	allPrices := make(map[string]*Node)
 	regions, err := getRegions("compute", sClient, providersClient, config.AzureSubscriptionID)
 	if err != nil {
 		return err
@@ -341,65 +398,129 @@ func (az *Azure) DownloadPricingData() error {
 	if err != nil {
 		return err
 	}
+	allPrices, diskPrices := azureMeterPrices(*result.Meters, regions, c)
+	az.allPrices = allPrices
+	az.diskPrices = diskPrices
+	return nil
+}
+
+// azureMeterPrices reads the prices of nodes, keyed by region, VM size, usage type and operating system, and of managed
+// disks, keyed by region and disk type, from the rate card meters.
+func azureMeterPrices(meters []commerce.MeterInfo, regions map[string]string, c *CustomPricing) (map[string]*Node, map[string][]*azureDiskTier) {
+	allPrices := make(map[string]*Node)
+	diskPrices := make(map[string][]*azureDiskTier)
 	baseCPUPrice := c.CPU
 
-	for _, v := range *result.Meters {
-		if !strings.Contains(*v.MeterSubCategory, "Windows") {
+	// Spot meters replace the Low Priority ones of the same VM size, when both exist.
+	spotKeys := make(map[string]bool)
+	for _, v := range meters {
+		region, err := toRegionID(*v.MeterRegion, regions)
+		if err != nil {
+			continue
+		}
 
-			region, err := toRegionID(*v.MeterRegion, regions)
-			if err != nil {
-				continue
-			}
+		meterName := *v.MeterName
+		sc := *v.MeterSubCategory
 
-			meterName := *v.MeterName
-			sc := *v.MeterSubCategory
+		// not available now
+		if strings.Contains(sc, "Promo") {
+			continue
+		}
 
-			// not available now
-			if strings.Contains(sc, "Promo") {
-				continue
+		if v.MeterCategory != nil && *v.MeterCategory == "Storage" {
+			if diskType, tier, ok := parseAzureDiskMeter(meterName); ok && azureDiskSubCategory(sc) {
+				for _, rate := range v.MeterRates {
+					tier.MonthlyPrice += *rate
+				}
+				addDiskTier(diskPrices, region+","+diskType, tier)
 			}
+			continue
+		}
 
-			usageType := ""
-			if !strings.Contains(meterName, "Low Priority") {
-				usageType = "ondemand"
-			} else {
-				usageType = "preemptible"
-			}
+		isSpot := strings.HasSuffix(meterName, " Spot")
+		usageType := ""
+		if !strings.Contains(meterName, "Low Priority") && !isSpot {
+			usageType = "ondemand"
+		} else {
+			usageType = "preemptible"
+		}
 
-			var instanceTypes []string
-			name := strings.TrimSuffix(meterName, " Low Priority")
-			instanceType := strings.Split(name, "/")
-			for _, it := range instanceType {
-				instanceTypes = append(instanceTypes, strings.Replace(it, " ", "_", 1))
-			}
+		// Windows meters include the license, so they are kept apart from Linux ones
+		osSuffix := ""
+		if strings.Contains(sc, "Windows") {
+			osSuffix = ",windows"
+		}
 
-			instanceTypes = transformMachineType(sc, instanceTypes)
-			if strings.Contains(name, "Expired") {
-				instanceTypes = []string{}
-			}
+		var instanceTypes []string
+		name := strings.TrimSuffix(strings.TrimSuffix(meterName, " Low Priority"), " Spot")
+		instanceType := strings.Split(name, "/")
+		for _, it := range instanceType {
+			instanceTypes = append(instanceTypes, strings.Replace(it, " ", "_", 1))
+		}
 
-			var priceInUsd float64
+		instanceTypes = transformMachineType(sc, instanceTypes)
+		if strings.Contains(name, "Expired") {
+			instanceTypes = []string{}
+		}
+
+		var priceInUsd float64
+
+		if len(v.MeterRates) < 1 {
+			klog.V(1).Infof("missing rate info %+v", map[string]interface{}{"MeterSubCategory": *v.MeterSubCategory, "region": region})
+			continue
+		}
+		for _, rate := range v.MeterRates {
+			priceInUsd += *rate
+		}
+		priceStr := fmt.Sprintf("%f", priceInUsd)
+		for _, instanceType := range instanceTypes {
 
-			if len(v.MeterRates) < 1 {
-				klog.V(1).Infof("missing rate info %+v", map[string]interface{}{"MeterSubCategory": *v.MeterSubCategory, "region": region})
+			key := fmt.Sprintf("%s,%s,%s%s", region, instanceType, usageType, osSuffix)
+			if spotKeys[key] && !isSpot {
 				continue
 			}
-			for _, rate := range v.MeterRates {
-				priceInUsd += *rate
+			if isSpot {
+				spotKeys[key] = true
 			}
-			priceStr := fmt.Sprintf("%f", priceInUsd)
-			for _, instanceType := range instanceTypes {
-
-				key := fmt.Sprintf("%s,%s,%s", region, instanceType, usageType)
-				allPrices[key] = &Node{
-					Cost:         priceStr,
-					BaseCPUPrice: baseCPUPrice,
-				}
+			node := &Node{
+				Cost:         priceStr,
+				BaseCPUPrice: baseCPUPrice,
+				UsageType:    usageType,
+			}
+			if sku, ok := azureGPUSkus[instanceType]; ok {
+				sku.splitCost(node, priceInUsd, c)
 			}
+			allPrices[key] = node
 		}
 	}
-	az.allPrices = allPrices
-	return nil
+	return allPrices, diskPrices
+}
+
+// splitCost sets the GPUs of a node and splits its price between its vCPUs, RAM and GPUs in proportion to the
+// default prices of each. If the defaults are missing, the cost model splits the price instead.
+func (sku *azureGPUSku) splitCost(node *Node, price float64, c *CustomPricing) {
+	node.GPU = strconv.Itoa(sku.GPU)
+	node.GPUName = sku.GPUName
+	cpuPrice, err := strconv.ParseFloat(c.CPU, 64)
+	if err != nil {
+		return
+	}
+	ramPrice, err := strconv.ParseFloat(c.RAM, 64)
+	if err != nil {
+		return
+	}
+	gpuPrice, err := strconv.ParseFloat(c.GPU, 64)
+	if err != nil {
+		return
+	}
+	weight := sku.VCPU*cpuPrice + sku.RAMGB*ramPrice + float64(sku.GPU)*gpuPrice
+	if weight == 0 {
+		return
+	}
+	scale := price / weight
+	node.VCPUCost = fmt.Sprintf("%f", cpuPrice*scale)
+	node.RAMCost = fmt.Sprintf("%f", ramPrice*scale)
+	node.GPUCost = fmt.Sprintf("%f", gpuPrice*scale)
 }
 
 // AllNodePricing returns the Azure pricing objects stored
@@ -422,11 +543,23 @@ func (az *Azure) NodePricing(key Key) (*Node, error) {
 	if err != nil {
 		return nil, fmt.Errorf("No default pricing data available")
 	}
-	return &Node{
+	n := &Node{
 		VCPUCost:         c.CPU,
 		RAMCost:          c.RAM,
 		UsesBaseCPUPrice: true,
-	}, nil
+	}
+	if strings.Contains(key.Features(), ",preemptible") {
+		n.VCPUCost = c.SpotCPU
+		n.RAMCost = c.SpotRAM
+	}
+	if k, ok := key.(*azureKey); ok {
+		if sku, ok := azureGPUSkus[k.Labels[v1.LabelInstanceType]]; ok {
+			n.GPU = strconv.Itoa(sku.GPU)
+			n.GPUName = sku.GPUName
+			n.GPUCost = c.GPU
+		}
+	}
+	return n, nil
 }
 
 // Stubbed NetworkPricing for Azure. Pull directly from azure.json for now
@@ -457,15 +590,20 @@ func (c *Azure) NetworkPricing() (*Network, error) {
 
 type azurePvKey struct {
 	Labels                 map[string]string
+	Region                 string
 	StorageClass           string
 	StorageClassParameters map[string]string
+	SizeGB                 float64
 }
 
 func (az *Azure) GetPVKey(pv *v1.PersistentVolume, parameters map[string]string) PVKey {
+	size := pv.Spec.Capacity[v1.ResourceStorage]
 	return &azurePvKey{
 		Labels:                 pv.Labels,
+		Region:                 azurePVRegion(pv),
 		StorageClass:           pv.Spec.StorageClassName,
 		StorageClassParameters: parameters,
+		SizeGB:                 float64(size.Value()) / 1024 / 1024 / 1024,
 	}
 }
 
@@ -473,14 +611,17 @@ func (key *azurePvKey) GetStorageClass() string {
 	return key.StorageClass
 }
 
+// Features returns the region and disk type of the volume. The disk SKU is the skuName parameter of CSI and newer
+// in-tree StorageClasses, or the storageaccounttype parameter of older ones, Standard_LRS by default.
 func (key *azurePvKey) Features() string {
-	storageClass := key.StorageClassParameters["type"]
-	if storageClass == "pd-ssd" {
-		storageClass = "ssd"
-	} else if storageClass == "pd-standard" {
-		storageClass = "pdstandard"
+	sku, ok := key.StorageClassParameters["skuName"]
+	if !ok {
+		sku, ok = key.StorageClassParameters["storageaccounttype"]
 	}
-	return key.Labels[v1.LabelZoneRegion] + "," + storageClass
+	if !ok {
+		sku = "Standard_LRS"
+	}
+	return strings.ToLower(key.Region) + "," + azureDiskTypes[strings.ToLower(sku)]
 }
 
 func (*Azure) GetDisks() ([]byte, error) {
@@ -560,14 +701,6 @@ func (az *Azure) GetConfig() (*CustomPricing, error) {
 	return c, nil
 }
 
-func (az *Azure) ExternalAllocations(string, string, string) ([]*OutOfClusterAllocation, error) {
-	return nil, nil
-}
-
-func (az *Azure) PVPricing(PVKey) (*PV, error) {
-	return nil, nil
-}
-
 func (az *Azure) GetLocalStorageQuery() (string, error) {
 	return "", nil
 }
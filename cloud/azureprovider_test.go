@@ -0,0 +1,206 @@
+package cloud
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/preview/commerce/mgmt/2015-06-01-preview/commerce"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestAzureKeyFeatures(t *testing.T) {
+	cases := []struct {
+		labels   map[string]string
+		features string
+		gpuType  string
+	}{
+		{
+			labels:   map[string]string{v1.LabelZoneRegion: "EastUS", v1.LabelInstanceType: "Standard_D2_v3", v1.LabelOSStable: "linux"},
+			features: "eastus,Standard_D2_v3,ondemand",
+		},
+		{
+			labels:   map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_D2_v3", azureSpotLabel: azureSpotValue},
+			features: "eastus,Standard_D2_v3,preemptible",
+		},
+		{
+			labels:   map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_D2_v3", azureSpotLabel: "regular"},
+			features: "eastus,Standard_D2_v3,ondemand",
+		},
+		{
+			labels:   map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_D2_v3", v1.LabelOSStable: "windows"},
+			features: "eastus,Standard_D2_v3,ondemand,windows",
+		},
+		{
+			labels:   map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_D2_v3", "beta.kubernetes.io/os": "Windows", azureSpotLabel: azureSpotValue},
+			features: "eastus,Standard_D2_v3,preemptible,windows",
+		},
+		{
+			labels:   map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_NC6"},
+			features: "eastus,Standard_NC6,ondemand",
+			gpuType:  "nvidia-tesla-k80",
+		},
+	}
+	az := &Azure{}
+	for _, c := range cases {
+		key := az.GetKey(c.labels)
+		if features := key.Features(); features != c.features {
+			t.Errorf("Features of %v are %q, expected %q", c.labels, features, c.features)
+		}
+		if gpuType := key.GPUType(); gpuType != c.gpuType {
+			t.Errorf("GPU type of %v is %q, expected %q", c.labels, gpuType, c.gpuType)
+		}
+	}
+}
+
+func testAzureMeter(category string, subCategory string, name string, region string, rate float64) commerce.MeterInfo {
+	return commerce.MeterInfo{
+		MeterName:        &name,
+		MeterCategory:    &category,
+		MeterSubCategory: &subCategory,
+		MeterRegion:      &region,
+		MeterRates:       map[string]*float64{"0": &rate},
+	}
+}
+
+func TestAzureMeterPrices(t *testing.T) {
+	meters := []commerce.MeterInfo{
+		testAzureMeter("Virtual Machines", "Dv3/DSv3 Series", "D2 v3/D2s v3", "US East", 0.1),
+		testAzureMeter("Virtual Machines", "Dv3/DSv3 Series", "D2 v3/D2s v3 Low Priority", "US East", 0.02),
+		testAzureMeter("Virtual Machines", "Dv3/DSv3 Series", "D2 v3/D2s v3 Spot", "US East", 0.015),
+		// The Spot meter is kept whichever comes first.
+		testAzureMeter("Virtual Machines", "Dv3/DSv3 Series", "D4 v3 Spot", "US East", 0.03),
+		testAzureMeter("Virtual Machines", "Dv3/DSv3 Series", "D4 v3 Low Priority", "US East", 0.04),
+		testAzureMeter("Virtual Machines", "Dv3/DSv3 Series Windows", "D2 v3/D2s v3", "US East", 0.2),
+		testAzureMeter("Virtual Machines", "NC Series", "NC6", "US East", 0.9),
+		testAzureMeter("Virtual Machines", "NC Series Promo", "NC12", "US East", 0.5),
+		testAzureMeter("Virtual Machines", "Dv3/DSv3 Series", "D8 v3", "Moon", 0.4),
+		testAzureMeter("Storage", "Premium SSD Managed Disks", "P10 Disks", "US East", 19.71),
+		testAzureMeter("Storage", "Premium SSD Managed Disks", "Disk Operations", "US East", 0.1),
+	}
+	regions := map[string]string{"eastus": "East US"}
+	c := &CustomPricing{CPU: "0.03", RAM: "0.004", GPU: "0.95"}
+	allPrices, diskPrices := azureMeterPrices(meters, regions, c)
+
+	expected := map[string]string{
+		"eastus,Standard_D2_v3,ondemand":          "0.100000",
+		"eastus,Standard_D2s_v3,ondemand":         "0.100000",
+		"eastus,Standard_D2_v3,preemptible":       "0.015000",
+		"eastus,Standard_D2s_v3,preemptible":      "0.015000",
+		"eastus,Standard_D4_v3,preemptible":       "0.030000",
+		"eastus,Standard_D2_v3,ondemand,windows":  "0.200000",
+		"eastus,Standard_D2s_v3,ondemand,windows": "0.200000",
+		"eastus,Standard_NC6,ondemand":            "0.900000",
+	}
+	if len(allPrices) != len(expected) {
+		t.Errorf("Got %d node prices, expected %d", len(allPrices), len(expected))
+	}
+	for key, cost := range expected {
+		n, ok := allPrices[key]
+		if !ok {
+			t.Errorf("Missing node price for %s", key)
+			continue
+		}
+		if n.Cost != cost {
+			t.Errorf("Cost of %s is %s, expected %s", key, n.Cost, cost)
+		}
+	}
+	if n := allPrices["eastus,Standard_D2_v3,preemptible"]; n != nil && n.UsageType != "preemptible" {
+		t.Errorf("Usage type of a spot node is %s, expected preemptible", n.UsageType)
+	}
+	if n := allPrices["eastus,Standard_NC6,ondemand"]; n != nil && (n.GPU != "1" || n.GPUName != "nvidia-tesla-k80") {
+		t.Errorf("GPU node has %s GPUs of type %s, expected 1 nvidia-tesla-k80", n.GPU, n.GPUName)
+	}
+	if tiers := diskPrices["eastus,"+azurePremiumSSD]; len(tiers) != 1 || tiers[0].Tier != "P10" || tiers[0].MonthlyPrice != 19.71 {
+		t.Errorf("Unexpected disk tiers %v", tiers)
+	}
+}
+
+func TestAzureGPUSkuSplitCost(t *testing.T) {
+	sku := azureGPUSkus["Standard_NC12"]
+	node := &Node{}
+	sku.splitCost(node, 2, &CustomPricing{CPU: "0.03", RAM: "0.004", GPU: "0.95"})
+	if node.GPU != "2" || node.GPUName != "nvidia-tesla-k80" {
+		t.Errorf("Node has %s GPUs of type %s, expected 2 nvidia-tesla-k80", node.GPU, node.GPUName)
+	}
+	cpu, _ := strconv.ParseFloat(node.VCPUCost, 64)
+	ram, _ := strconv.ParseFloat(node.RAMCost, 64)
+	gpu, _ := strconv.ParseFloat(node.GPUCost, 64)
+	// The node price is split in proportion to the default prices, so the parts add up to it.
+	if total := 12*cpu + 112*ram + 2*gpu; math.Abs(total-2) > 1e-4 {
+		t.Errorf("Split costs add up to %f, expected 2", total)
+	}
+	if math.Abs(cpu/gpu-0.03/0.95) > 1e-4 || math.Abs(ram/gpu-0.004/0.95) > 1e-4 {
+		t.Errorf("Split costs %f, %f and %f are not in proportion to the default prices", cpu, ram, gpu)
+	}
+
+	for _, c := range []*CustomPricing{{CPU: "0.03", RAM: "0.004"}, {CPU: "0", RAM: "0", GPU: "0"}} {
+		node := &Node{}
+		sku.splitCost(node, 2, c)
+		if node.GPU != "2" || node.VCPUCost != "" || node.RAMCost != "" || node.GPUCost != "" {
+			t.Errorf("Without default prices %+v, node is %+v, expected only its GPUs to be set", *c, *node)
+		}
+	}
+}
+
+func TestAzureNodePricingFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "azure")
+	if err != nil {
+		t.Fatalf("Unable to create the config directory: %s", err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	config := `{"CPU": "0.03", "spotCPU": "0.006", "RAM": "0.004", "spotRAM": "0.0008", "GPU": "0.95"}`
+	if err := ioutil.WriteFile(filepath.Join(dir, "azure.json"), []byte(config), 0644); err != nil {
+		t.Fatalf("Unable to write the config: %s", err.Error())
+	}
+	configPath, ok := os.LookupEnv("CONFIG_PATH")
+	os.Setenv("CONFIG_PATH", dir+"/")
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("CONFIG_PATH", configPath)
+		} else {
+			os.Unsetenv("CONFIG_PATH")
+		}
+	})
+
+	az := &Azure{
+		allPrices: map[string]*Node{
+			"eastus,Standard_D2_v3,ondemand": {Cost: "0.1"},
+		},
+	}
+	cases := []struct {
+		labels map[string]string
+		node   Node
+	}{
+		{
+			labels: map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_D2_v3"},
+			node:   Node{Cost: "0.1"},
+		},
+		{
+			labels: map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_D4_v3"},
+			node:   Node{VCPUCost: "0.03", RAMCost: "0.004", UsesBaseCPUPrice: true},
+		},
+		{
+			labels: map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_D2_v3", azureSpotLabel: azureSpotValue},
+			node:   Node{VCPUCost: "0.006", RAMCost: "0.0008", UsesBaseCPUPrice: true},
+		},
+		{
+			labels: map[string]string{v1.LabelZoneRegion: "eastus", v1.LabelInstanceType: "Standard_NC24", azureSpotLabel: azureSpotValue},
+			node:   Node{VCPUCost: "0.006", RAMCost: "0.0008", GPU: "4", GPUName: "nvidia-tesla-k80", GPUCost: "0.95", UsesBaseCPUPrice: true},
+		},
+	}
+	for _, c := range cases {
+		n, err := az.NodePricing(az.GetKey(c.labels))
+		if err != nil {
+			t.Errorf("%v: unexpected error: %s", c.labels, err.Error())
+			continue
+		}
+		if n.Cost != c.node.Cost || n.VCPUCost != c.node.VCPUCost || n.RAMCost != c.node.RAMCost || n.GPU != c.node.GPU ||
+			n.GPUName != c.node.GPUName || n.GPUCost != c.node.GPUCost || n.UsesBaseCPUPrice != c.node.UsesBaseCPUPrice {
+			t.Errorf("%v: priced as %+v, expected %+v", c.labels, *n, c.node)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package cloud
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// gcpDiscountRefreshInterval is the minimum time between two refreshes of the discounts triggered by pricing lookups.
+const gcpDiscountRefreshInterval = 10 * time.Minute
+
+// gcpDefaultDiscount discounts the rates of on-demand nodes without a computed discount, such as nodes that joined
+// since the last refresh, by 30%, the former default of CustomPricing.Discount.
+var gcpDefaultDiscount = &gcpNodeDiscount{
+	CPU: 0.7,
+	RAM: 0.7,
+	GPU: 0.7,
+}
+
+// gcpSustainedUseTiers are the fractions of the base rate charged for each successive quarter of a month an instance
+// runs, by machine family. Families absent from the map, such as e2 or preemptible instances, get no sustained use
+// discount.
+var gcpSustainedUseTiers = map[string][]float64{
+	"n1":     {1, 0.8, 0.6, 0.4},
+	"custom": {1, 0.8, 0.6, 0.4}, // n1 custom machine types
+	"m1":     {1, 0.8, 0.6, 0.4},
+	"m2":     {1, 0.8, 0.6, 0.4},
+	"f1":     {1, 0.8, 0.6, 0.4},
+	"g1":     {1, 0.8, 0.6, 0.4},
+	"n2":     {1, 0.8678, 0.733, 0.6},
+	"n2d":    {1, 0.8678, 0.733, 0.6},
+	"c2":     {1, 0.8678, 0.733, 0.6},
+}
+
+// gcpGPUSustainedUseTiers apply to attached GPUs whatever the machine family.
+var gcpGPUSustainedUseTiers = []float64{1, 0.8, 0.6, 0.4}
+
+// gcpCommitment is a committed use discount on a number of vCPUs and GB of RAM in a region.
+type gcpCommitment struct {
+	Region   string
+	VCPU     float64
+	RAMGB    float64
+	Discount float64
+}
+
+// gcpNodeDiscount holds the fractions of the on-demand rates a node is charged after discounts.
+type gcpNodeDiscount struct {
+	CPU float64
+	RAM float64
+	GPU float64
+}
+
+// parseGCPCommitments reads a comma separated list of "region:vcpus:ramGB:discount" entries, such as
+// "us-central1:32:120:37%" for a one year commitment of 32 vCPUs and 120GB of RAM.
+func parseGCPCommitments(commitments string) []*gcpCommitment {
+	var result []*gcpCommitment
+	for _, cm := range strings.Split(commitments, ",") {
+		if strings.TrimSpace(cm) == "" {
+			continue
+		}
+		fields := strings.Split(strings.TrimSpace(cm), ":")
+		if len(fields) != 4 {
+			klog.V(1).Infof("Ignoring invalid committed use discount %s", cm)
+			continue
+		}
+		vcpu, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || vcpu < 0 {
+			klog.V(1).Infof("Ignoring invalid committed use discount %s", cm)
+			continue
+		}
+		ram, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || ram < 0 {
+			klog.V(1).Infof("Ignoring invalid committed use discount %s", cm)
+			continue
+		}
+		discount, err := strconv.ParseFloat(strings.TrimSuffix(fields[3], "%"), 64)
+		if err != nil || discount < 0 || discount >= 100 {
+			klog.V(1).Infof("Ignoring invalid committed use discount %s", cm)
+			continue
+		}
+		result = append(result, &gcpCommitment{
+			Region:   fields[0],
+			VCPU:     vcpu,
+			RAMGB:    ram,
+			Discount: discount * 0.01,
+		})
+	}
+	return result
+}
+
+// gcpInstanceName returns the instance name of a provider ID of the form gce://project/zone/instance.
+func gcpInstanceName(providerID string) string {
+	if !strings.HasPrefix(providerID, "gce://") {
+		return ""
+	}
+	parts := strings.Split(providerID, "/")
+	return parts[len(parts)-1]
+}
+
+// gcpMachineFamily returns the family of a machine type, e.g. n1 for n1-standard-4 and custom for custom-4-16384.
+func gcpMachineFamily(machineType string) string {
+	return strings.ToLower(strings.Split(machineType, "-")[0])
+}
+
+// sustainedUseMultiplier returns the average fraction of the base rate charged for an instance running the given
+// fraction of the month.
+func sustainedUseMultiplier(tiers []float64, fraction float64) float64 {
+	if len(tiers) == 0 || fraction <= 0 {
+		return 1
+	}
+	fraction = math.Min(fraction, 1)
+	tierSize := 1 / float64(len(tiers))
+	charged := 0.0
+	for i, m := range tiers {
+		charged += math.Max(math.Min(fraction-float64(i)*tierSize, tierSize), 0) * m
+	}
+	return charged / fraction
+}
+
+// gcpDiscounts computes the discounts of each on-demand node, by instance name. Committed vCPUs and RAM cover nodes in
+// name order until exhausted. Usage a commitment does not cover gets the sustained use discount of the fraction of
+// the month the node will have run by the end of the month, assuming it keeps running.
+func gcpDiscounts(nodes []v1.Node, commitments []*gcpCommitment, now time.Time) map[string]*gcpNodeDiscount {
+	nodes = append([]v1.Node(nil), nodes...)
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	remainingCPU := make([]float64, len(commitments))
+	remainingRAM := make([]float64, len(commitments))
+	for i, cm := range commitments {
+		remainingCPU[i] = cm.VCPU
+		remainingRAM[i] = cm.RAMGB
+	}
+
+	result := make(map[string]*gcpNodeDiscount)
+	for _, n := range nodes {
+		name := gcpInstanceName(n.Spec.ProviderID)
+		if name == "" || n.Labels["cloud.google.com/gke-preemptible"] == "true" {
+			continue
+		}
+		start := n.CreationTimestamp.Time
+		if start.Before(monthStart) {
+			start = monthStart
+		}
+		fraction := monthEnd.Sub(start).Hours() / monthEnd.Sub(monthStart).Hours()
+		sud := sustainedUseMultiplier(gcpSustainedUseTiers[gcpMachineFamily(n.Labels[v1.LabelInstanceType])], fraction)
+
+		cpu := float64(n.Status.Capacity.Cpu().MilliValue()) / 1000
+		ram := float64(n.Status.Capacity.Memory().Value()) / 1024 / 1024 / 1024
+		region := n.Labels[v1.LabelZoneRegion]
+		coveredCPU, coveredRAM, cudCPU, cudRAM := 0.0, 0.0, 0.0, 0.0
+		for i, cm := range commitments {
+			if cm.Region != region {
+				continue
+			}
+			c := math.Min(cpu-coveredCPU, remainingCPU[i])
+			remainingCPU[i] -= c
+			coveredCPU += c
+			cudCPU += c * (1 - cm.Discount)
+			r := math.Min(ram-coveredRAM, remainingRAM[i])
+			remainingRAM[i] -= r
+			coveredRAM += r
+			cudRAM += r * (1 - cm.Discount)
+		}
+
+		discount := &gcpNodeDiscount{
+			CPU: sud,
+			RAM: sud,
+			GPU: sustainedUseMultiplier(gcpGPUSustainedUseTiers, fraction),
+		}
+		if cpu > 0 {
+			discount.CPU = (cudCPU + (cpu-coveredCPU)*sud) / cpu
+		}
+		if ram > 0 {
+			discount.RAM = (cudRAM + (ram-coveredRAM)*sud) / ram
+		}
+		result[name] = discount
+	}
+	return result
+}
+
+// setDiscounts computes the discounts of the given nodes. The caller must hold discountsLock.
+func (gcp *GCP) setDiscounts(nodes []v1.Node, c *CustomPricing, now time.Time) {
+	gcp.DiscountsByInstance = gcpDiscounts(nodes, parseGCPCommitments(c.GcpCommittedUse), now)
+	gcp.discountsComputed = now
+	gcp.discountsAttempted = now
+}
+
+// nodeDiscount returns the discount of an on-demand instance. The discounts are recomputed from the cluster's nodes
+// when a new month starts, as sustained use restarts from the first tier, and when the instance is unknown, as a new
+// node may take over committed use or join the cluster between pricing downloads. Refreshes happen at most once per
+// gcpDiscountRefreshInterval, and list the nodes without holding discountsLock.
+func (gcp *GCP) nodeDiscount(id string) (*gcpNodeDiscount, bool) {
+	now := time.Now().UTC()
+	gcp.discountsLock.Lock()
+	d, ok := gcp.DiscountsByInstance[id]
+	newMonth := now.Year() != gcp.discountsComputed.Year() || now.Month() != gcp.discountsComputed.Month()
+	refresh := (!ok || newMonth) && now.Sub(gcp.discountsAttempted) >= gcpDiscountRefreshInterval
+	if refresh {
+		gcp.discountsAttempted = now
+	}
+	gcp.discountsLock.Unlock()
+	if !refresh {
+		return d, ok
+	}
+
+	c, err := gcp.GetConfig()
+	if err != nil {
+		klog.V(1).Infof("Unable to refresh GCP discounts: %s", err.Error())
+		return d, ok
+	}
+	nodeList, err := gcp.Clientset.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		klog.V(1).Infof("Unable to refresh GCP discounts: %s", err.Error())
+		return d, ok
+	}
+	gcp.discountsLock.Lock()
+	defer gcp.discountsLock.Unlock()
+	gcp.setDiscounts(nodeList.Items, c, now)
+	d, ok = gcp.DiscountsByInstance[id]
+	return d, ok
+}
+
+// applyDiscount returns a copy of the node with its CPU, RAM and GPU rates discounted.
+func (d *gcpNodeDiscount) applyDiscount(n *Node) *Node {
+	discounted := *n
+	scale := func(cost string, multiplier float64) string {
+		c, err := strconv.ParseFloat(cost, 64)
+		if err != nil {
+			return cost
+		}
+		return strconv.FormatFloat(c*multiplier, 'f', -1, 64)
+	}
+	discounted.VCPUCost = scale(n.VCPUCost, d.CPU)
+	discounted.RAMCost = scale(n.RAMCost, d.RAM)
+	discounted.GPUCost = scale(n.GPUCost, d.GPU)
+	return &discounted
+}
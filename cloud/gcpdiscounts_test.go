@@ -0,0 +1,159 @@
+package cloud
+
+import (
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSustainedUseMultiplier(t *testing.T) {
+	n1 := gcpSustainedUseTiers["n1"]
+	cases := []struct {
+		tiers    []float64
+		fraction float64
+		expected float64
+	}{
+		{n1, 0, 1},
+		{n1, 0.1, 1},
+		{n1, 0.25, 1},
+		{n1, 0.5, 0.9},
+		{n1, 0.75, 0.8},
+		{n1, 1, 0.7},
+		{n1, 1.5, 0.7},
+		{gcpSustainedUseTiers["n2"], 1, (1 + 0.8678 + 0.733 + 0.6) / 4},
+		{nil, 1, 1},
+	}
+	for _, c := range cases {
+		if m := sustainedUseMultiplier(c.tiers, c.fraction); math.Abs(m-c.expected) > 1e-9 {
+			t.Errorf("Multiplier of tiers %v at %f of the month is %f, expected %f", c.tiers, c.fraction, m, c.expected)
+		}
+	}
+}
+
+func testGCPNode(name string, instanceType string, region string, created time.Time) v1.Node {
+	return v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				v1.LabelInstanceType: instanceType,
+				v1.LabelZoneRegion:   region,
+			},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "gce://project/" + region + "-a/" + name,
+		},
+		Status: v1.NodeStatus{
+			Capacity: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse("4"),
+				v1.ResourceMemory: resource.MustParse("16Gi"),
+			},
+		},
+	}
+}
+
+func TestGCPDiscounts(t *testing.T) {
+	now := time.Date(2020, time.March, 20, 0, 0, 0, 0, time.UTC)
+	preemptible := testGCPNode("node-e", "n1-standard-4", "us-central1", now.AddDate(0, -1, 0))
+	preemptible.Labels["cloud.google.com/gke-preemptible"] = "true"
+	unmanaged := testGCPNode("node-f", "n1-standard-4", "us-central1", now.AddDate(0, -1, 0))
+	unmanaged.Spec.ProviderID = ""
+	nodes := []v1.Node{
+		// Created in March, the node runs a quarter of the month, within the first tier.
+		testGCPNode("node-c", "n1-standard-4", "us-central1", time.Date(2020, time.March, 24, 6, 0, 0, 0, time.UTC)),
+		// Running since February, the node gets the full sustained use discount, and is covered first by the commitment.
+		testGCPNode("node-a", "n1-standard-4", "us-central1", now.AddDate(0, -1, 0)),
+		// The commitment covers the rest of the vCPUs but no more RAM. The node runs half the month.
+		testGCPNode("node-b", "n1-standard-4", "us-central1", time.Date(2020, time.March, 16, 12, 0, 0, 0, time.UTC)),
+		// The commitment does not cover other regions, and e2 instances get no sustained use discount.
+		testGCPNode("node-d", "e2-standard-4", "europe-west1", now.AddDate(0, -1, 0)),
+		preemptible,
+		unmanaged,
+	}
+	commitments := parseGCPCommitments("us-central1:6:16:37%")
+
+	discounts := gcpDiscounts(nodes, commitments, now)
+	expected := map[string]*gcpNodeDiscount{
+		"node-a": {CPU: 0.63, RAM: 0.63, GPU: 0.7},
+		"node-b": {CPU: (2*0.63 + 2*0.9) / 4, RAM: 0.9, GPU: 0.9},
+		"node-c": {CPU: 1, RAM: 1, GPU: 1},
+		"node-d": {CPU: 1, RAM: 1, GPU: 0.7},
+	}
+	if len(discounts) != len(expected) {
+		t.Errorf("Got discounts for %d nodes, expected %d", len(discounts), len(expected))
+	}
+	for name, e := range expected {
+		d, ok := discounts[name]
+		if !ok {
+			t.Errorf("Missing discount for %s", name)
+			continue
+		}
+		if math.Abs(d.CPU-e.CPU) > 1e-9 || math.Abs(d.RAM-e.RAM) > 1e-9 || math.Abs(d.GPU-e.GPU) > 1e-9 {
+			t.Errorf("Discount of %s is %+v, expected %+v", name, *d, *e)
+		}
+	}
+	if nodes[0].Name != "node-c" || nodes[1].Name != "node-a" {
+		t.Errorf("The nodes passed in were reordered")
+	}
+}
+
+func TestGCPNodePricingDiscounts(t *testing.T) {
+	now := time.Now().UTC()
+	gcp := &GCP{
+		Pricing: make(map[string]*GCPPricing),
+		DiscountsByInstance: map[string]*gcpNodeDiscount{
+			"node-a": {CPU: 0.5, RAM: 0.8, GPU: 1},
+		},
+		// Computed this month and just attempted, so that lookups do not refresh the discounts.
+		discountsComputed:  now,
+		discountsAttempted: now,
+	}
+	preemptible := testGCPNode("node-c", "n1-standard-4", "us-central1", now)
+	preemptible.Labels["cloud.google.com/gke-preemptible"] = "true"
+	cases := []struct {
+		node v1.Node
+		cpu  float64
+		ram  float64
+	}{
+		{testGCPNode("node-a", "n1-standard-4", "us-central1", now), 0.05, 0.08},
+		// A node without a computed discount gets the default 30% discount.
+		{testGCPNode("node-b", "n1-standard-4", "us-central1", now), 0.07, 0.07},
+		{preemptible, 0.1, 0.1},
+	}
+	for _, c := range cases {
+		labels := map[string]string{"providerID": c.node.Spec.ProviderID}
+		for k, v := range c.node.Labels {
+			labels[k] = v
+		}
+		key := gcp.GetKey(labels)
+		gcp.Pricing[key.Features()] = &GCPPricing{Node: &Node{VCPUCost: "0.1", RAMCost: "0.1"}}
+		n, err := gcp.NodePricing(key)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", c.node.Name, err.Error())
+			continue
+		}
+		cpu, _ := strconv.ParseFloat(n.VCPUCost, 64)
+		ram, _ := strconv.ParseFloat(n.RAMCost, 64)
+		if math.Abs(cpu-c.cpu) > 1e-9 || math.Abs(ram-c.ram) > 1e-9 {
+			t.Errorf("%s: priced %s per vCPU and %s per GB, expected %f and %f", c.node.Name, n.VCPUCost, n.RAMCost, c.cpu, c.ram)
+		}
+	}
+}
+
+func TestParseGCPCommitments(t *testing.T) {
+	commitments := parseGCPCommitments("us-central1:32:120:37%, europe-west1:8:30:55, invalid, us-east1:a:1:10%, us-east1:1:1:100%")
+	if len(commitments) != 2 {
+		t.Fatalf("Parsed %d commitments, expected 2", len(commitments))
+	}
+	if c := commitments[0]; c.Region != "us-central1" || c.VCPU != 32 || c.RAMGB != 120 || math.Abs(c.Discount-0.37) > 1e-9 {
+		t.Errorf("Unexpected commitment %+v", *c)
+	}
+	if c := commitments[1]; c.Region != "europe-west1" || c.VCPU != 8 || c.RAMGB != 30 || math.Abs(c.Discount-0.55) > 1e-9 {
+		t.Errorf("Unexpected commitment %+v", *c)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"k8s.io/klog"
 
@@ -50,6 +51,11 @@ type GCP struct {
 	ProjectID               string
 	BillingDataDataset      string
 	DownloadPricingDataLock sync.RWMutex
+	// DiscountsByInstance holds the sustained and committed use discounts of on-demand instances
+	DiscountsByInstance map[string]*gcpNodeDiscount
+	discountsComputed   time.Time
+	discountsAttempted  time.Time
+	discountsLock       sync.Mutex
 	*CustomProvider
 }
 
@@ -90,7 +96,7 @@ func (gcp *GCP) GetConfig() (*CustomPricing, error) {
 		return nil, err
 	}
 	if c.Discount == "" {
-		c.Discount = "30%"
+		c.Discount = "0%" // Sustained and committed use discounts are applied to each node's rates
 	}
 	return c, nil
 }
@@ -710,6 +716,9 @@ func (gcp *GCP) DownloadPricingData() error {
 		return err
 	}
 	gcp.Pricing = pages
+	gcp.discountsLock.Lock()
+	gcp.setDiscounts(nodeList.Items, c, time.Now().UTC())
+	gcp.discountsLock.Unlock()
 	return nil
 }
 
@@ -780,17 +789,20 @@ func (key *pvKey) Features() string {
 }
 
 type gcpKey struct {
-	Labels map[string]string
+	Labels     map[string]string
+	ProviderID string
 }
 
 func (gcp *GCP) GetKey(labels map[string]string) Key {
 	return &gcpKey{
-		Labels: labels,
+		Labels:     labels,
+		ProviderID: labels["providerID"],
 	}
 }
 
+// ID returns the name of the node's instance, if known
 func (gcp *gcpKey) ID() string {
-	return ""
+	return gcpInstanceName(gcp.ProviderID)
 }
 
 func (gcp *gcpKey) GPUType() string {
@@ -845,6 +857,12 @@ func (gcp *GCP) NodePricing(key Key) (*Node, error) {
 	if n, ok := gcp.Pricing[key.Features()]; ok {
 		klog.V(4).Infof("Returning pricing for node %s: %+v from SKU %s", key, n.Node, n.Name)
 		n.Node.BaseCPUPrice = gcp.BaseCPUPrice
+		if d, ok := gcp.nodeDiscount(key.ID()); ok {
+			return d.applyDiscount(n.Node), nil
+		}
+		if !strings.Contains(key.Features(), ",preemptible") {
+			return gcpDefaultDiscount.applyDiscount(n.Node), nil
+		}
 		return n.Node, nil
 	}
 	klog.V(1).Infof("Warning: no pricing data found for %s: %s", key.Features(), key)
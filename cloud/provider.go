@@ -55,6 +55,7 @@ type Node struct {
 	GPU              string `json:"gpu"` // GPU represents the number of GPU on the instance
 	GPUName          string `json:"gpuName"`
 	GPUCost          string `json:"gpuCost"`
+	Tenant           string `json:"tenant,omitempty"` // Tenant the node is dedicated to, see CustomPricing.DedicatedNodeLabel
 }
 
 // Network is the interface by which the provider and cost model communicate network egress prices.
@@ -173,41 +174,62 @@ func GetDefaultPricingData(fname string) (*CustomPricing, error) {
 const KeyUpdateType = "athenainfo"
 
 type CustomPricing struct {
-	Provider              string `json:"provider"`
-	Description           string `json:"description"`
-	CPU                   string `json:"CPU"`
-	SpotCPU               string `json:"spotCPU"`
-	RAM                   string `json:"RAM"`
-	SpotRAM               string `json:"spotRAM"`
-	GPU                   string `json:"GPU"`
-	SpotGPU               string `json:"spotGPU"`
-	Storage               string `json:"storage"`
-	ZoneNetworkEgress     string `json:"zoneNetworkEgress"`
-	RegionNetworkEgress   string `json:"regionNetworkEgress"`
-	InternetNetworkEgress string `json:"internetNetworkEgress"`
-	SpotLabel             string `json:"spotLabel,omitempty"`
-	SpotLabelValue        string `json:"spotLabelValue,omitempty"`
-	GpuLabel              string `json:"gpuLabel,omitempty"`
-	GpuLabelValue         string `json:"gpuLabelValue,omitempty"`
-	ServiceKeyName        string `json:"awsServiceKeyName,omitempty"`
-	ServiceKeySecret      string `json:"awsServiceKeySecret,omitempty"`
-	SpotDataRegion        string `json:"awsSpotDataRegion,omitempty"`
-	SpotDataBucket        string `json:"awsSpotDataBucket,omitempty"`
-	SpotDataPrefix        string `json:"awsSpotDataPrefix,omitempty"`
-	ProjectID             string `json:"projectID,omitempty"`
-	AthenaBucketName      string `json:"athenaBucketName"`
-	AthenaRegion          string `json:"athenaRegion"`
-	AthenaDatabase        string `json:"athenaDatabase"`
-	AthenaTable           string `json:"athenaTable"`
-	BillingDataDataset    string `json:"billingDataDataset,omitempty"`
-	CustomPricesEnabled   string `json:"customPricesEnabled"`
-	AzureSubscriptionID   string `json:"azureSubscriptionID"`
-	AzureClientID         string `json:"azureClientID"`
-	AzureClientSecret     string `json:"azureClientSecret"`
-	AzureTenantID         string `json:"azureTenantID"`
-	CurrencyCode          string `json:"currencyCode"`
-	Discount              string `json:"discount"`
-	ClusterName           string `json:"clusterName"`
+	Provider                      string `json:"provider"`
+	Description                   string `json:"description"`
+	CPU                           string `json:"CPU"`
+	SpotCPU                       string `json:"spotCPU"`
+	RAM                           string `json:"RAM"`
+	SpotRAM                       string `json:"spotRAM"`
+	GPU                           string `json:"GPU"`
+	SpotGPU                       string `json:"spotGPU"`
+	Storage                       string `json:"storage"`
+	ZoneNetworkEgress             string `json:"zoneNetworkEgress"`
+	RegionNetworkEgress           string `json:"regionNetworkEgress"`
+	InternetNetworkEgress         string `json:"internetNetworkEgress"`
+	SpotLabel                     string `json:"spotLabel,omitempty"`
+	SpotLabelValue                string `json:"spotLabelValue,omitempty"`
+	OwnerLabel                    string `json:"ownerLabel,omitempty"`
+	DepartmentLabel               string `json:"departmentLabel,omitempty"`
+	ProductLabel                  string `json:"productLabel,omitempty"`
+	EnvironmentLabel              string `json:"environmentLabel,omitempty"`
+	AnnotationsAllowlist          string `json:"annotationsAllowlist,omitempty"`
+	AllocationStrategy            string `json:"allocationStrategy,omitempty"`
+	AllocationWeight              string `json:"allocationWeight,omitempty"`
+	QoSClassPriceMultipliers      string `json:"qosClassPriceMultipliers,omitempty"`
+	PriorityClassPriceMultipliers string `json:"priorityClassPriceMultipliers,omitempty"`
+	DedicatedNodeLabel            string `json:"dedicatedNodeLabel,omitempty"`
+	DedicatedNodeTaint            string `json:"dedicatedNodeTaint,omitempty"`
+	AnomalyDetectionLabels        string `json:"anomalyDetectionLabels,omitempty"`
+	BudgetWebhookURLs             string `json:"budgetWebhookURLs,omitempty"`
+	CostMetricsLabels             string `json:"costMetricsLabels,omitempty"`
+	GpuLabel                      string `json:"gpuLabel,omitempty"`
+	GpuLabelValue                 string `json:"gpuLabelValue,omitempty"`
+	ServiceKeyName                string `json:"awsServiceKeyName,omitempty"`
+	ServiceKeySecret              string `json:"awsServiceKeySecret,omitempty"`
+	SpotDataRegion                string `json:"awsSpotDataRegion,omitempty"`
+	SpotDataBucket                string `json:"awsSpotDataBucket,omitempty"`
+	SpotDataPrefix                string `json:"awsSpotDataPrefix,omitempty"`
+	ProjectID                     string `json:"projectID,omitempty"`
+	AwsReservedInstances          string `json:"awsReservedInstances,omitempty"`
+	AwsSavingsPlans               string `json:"awsSavingsPlans,omitempty"`
+	AwsReservedPricingFromCUR     string `json:"awsReservedPricingFromCUR,omitempty"`
+	AthenaBucketName              string `json:"athenaBucketName"`
+	AthenaRegion                  string `json:"athenaRegion"`
+	AthenaDatabase                string `json:"athenaDatabase"`
+	AthenaTable                   string `json:"athenaTable"`
+	BillingDataDataset            string `json:"billingDataDataset,omitempty"`
+	GcpCommittedUse               string `json:"gcpCommittedUse,omitempty"`
+	CustomPricesEnabled           string `json:"customPricesEnabled"`
+	AzureSubscriptionID           string `json:"azureSubscriptionID"`
+	AzureClientID                 string `json:"azureClientID"`
+	AzureClientSecret             string `json:"azureClientSecret"`
+	AzureTenantID                 string `json:"azureTenantID"`
+	AzureBillingExportPath        string `json:"azureBillingExportPath,omitempty"`
+	AzureBillingExportContainer   string `json:"azureBillingExportContainer,omitempty"`
+	AzureBillingExportPrefix      string `json:"azureBillingExportPrefix,omitempty"`
+	CurrencyCode                  string `json:"currencyCode"`
+	Discount                      string `json:"discount"`
+	ClusterName                   string `json:"clusterName"`
 }
 
 func SetCustomPricingField(obj *CustomPricing, name string, value string) error {
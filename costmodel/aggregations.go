@@ -4,94 +4,369 @@ import (
 	"math"
 	"sort"
 	"strconv"
+	"strings"
 )
 
 type Aggregation struct {
-	Aggregator         string    `json:"aggregation"`
-	AggregatorSubField string    `json:"aggregationSubfield"`
-	Environment        string    `json:"environment"`
-	Cluster            string    `json:"cluster"`
-	CPUAllocation      []*Vector `json:"-"`
-	CPUCostVector      []*Vector `json:"-"`
-	RAMAllocation      []*Vector `json:"-"`
-	RAMCostVector      []*Vector `json:"-"`
-	PVCostVector       []*Vector `json:"-"`
-	GPUAllocation      []*Vector `json:"-"`
-	GPUCostVector      []*Vector `json:"-"`
-	CPUCost            float64   `json:"cpuCost"`
-	RAMCost            float64   `json:"ramCost"`
-	GPUCost            float64   `json:"gpuCost"`
-	PVCost             float64   `json:"pvCost"`
-	NetworkCost        float64   `json:"networkCost"`
-	TotalCost          float64   `json:"totalCost"`
+	Aggregator              string                 `json:"aggregation"`
+	AggregatorSubField      string                 `json:"aggregationSubfield"`
+	Environment             string                 `json:"environment"`
+	Cluster                 string                 `json:"cluster"`
+	Properties              map[string]string      `json:"properties,omitempty"`
+	CPUAllocation           []*Vector              `json:"-"`
+	CPUCostVector           []*Vector              `json:"-"`
+	RAMAllocation           []*Vector              `json:"-"`
+	RAMCostVector           []*Vector              `json:"-"`
+	PVCostVector            []*Vector              `json:"-"`
+	GPUAllocation           []*Vector              `json:"-"`
+	GPUCostVector           []*Vector              `json:"-"`
+	CPURequested            []*Vector              `json:"-"`
+	CPUUsed                 []*Vector              `json:"-"`
+	RAMRequested            []*Vector              `json:"-"`
+	RAMUsed                 []*Vector              `json:"-"`
+	CPUWasteVector          []*Vector              `json:"-"`
+	RAMWasteVector          []*Vector              `json:"-"`
+	NetworkCostVector       []*Vector              `json:"-"`
+	NetworkZoneVector       []*Vector              `json:"-"`
+	NetworkRegionVector     []*Vector              `json:"-"`
+	NetworkInternetVector   []*Vector              `json:"-"`
+	CPUCost                 float64                `json:"cpuCost"`
+	RAMCost                 float64                `json:"ramCost"`
+	GPUCost                 float64                `json:"gpuCost"`
+	PVCost                  float64                `json:"pvCost"`
+	NetworkCost             float64                `json:"networkCost"`
+	NetworkZoneCost         float64                `json:"networkZoneCost"`
+	NetworkRegionCost       float64                `json:"networkRegionCost"`
+	NetworkInternetCost     float64                `json:"networkInternetCost"`
+	SharedCost              float64                `json:"sharedCost"`
+	CPURequestEfficiency    float64                `json:"cpuRequestEfficiency"`
+	CPUAllocationEfficiency float64                `json:"cpuAllocationEfficiency"`
+	RAMRequestEfficiency    float64                `json:"ramRequestEfficiency"`
+	RAMAllocationEfficiency float64                `json:"ramAllocationEfficiency"`
+	CPUWastedCost           float64                `json:"cpuWastedCost"`
+	RAMWastedCost           float64                `json:"ramWastedCost"`
+	WastedCost              float64                `json:"wastedCost"`
+	TotalCost               float64                `json:"totalCost"`
+	TimeSeries              []*CostTimeSeriesPoint `json:"timeseries,omitempty"`
 }
 
-func AggregateCostModel(costData map[string]*CostData, discount float64, aggregationField string, aggregationSubField string) map[string]*Aggregation {
+// AggregationOptions configures how AggregateCostModel prices and groups cost data.
+type AggregationOptions struct {
+	Discount    float64 // fraction to discount CPU, RAM, GPU and PV costs by, e.g. 0.3
+	IncludeIdle bool    // report unallocated node capacity under IdleKey
+	IdleByNode  bool    // compute idle per node rather than per cluster
+	ShareIdle   bool    // distribute idle across the aggregations in proportion to their cost
+	Shared      *SharedResourceInfo
+	Pricing     *PricingRules // scales container costs by QoS class and PriorityClass
+	// DedicatedNodes charges the full cost of nodes dedicated to a tenant to that tenant, see CustomPricing.DedicatedNodeLabel
+	DedicatedNodes bool
+	// DedicatedNodeCosts are the dedicated nodes, keyed by cluster and node, charged in full even if they ran no containers
+	DedicatedNodeCosts map[string]*DedicatedNode
+	// TenantLevel is the aggregation level tenants are reported under, e.g. "label:team", see tenantLevelIndex
+	TenantLevel string
+}
+
+func AggregateCostModel(costData map[string]*CostData, aggregationField string, aggregationSubField string, opts *AggregationOptions) map[string]*Aggregation {
+	if opts == nil {
+		opts = &AggregationOptions{}
+	}
+	discount := opts.Discount
+
+	// Dedicated nodes are charged in full to their tenant, so they have no idle.
+	undedicated := costData
+	var dedicated map[string]*idleCosts
+	var tenants map[string]string
+	if opts.DedicatedNodes {
+		var dedicatedData map[string]*CostData
+		undedicated, dedicatedData, tenants = splitDedicatedCostData(costData)
+		dedicated = computeIdleCosts(dedicatedData, discount, opts.Pricing, true)
+	}
+
+	var idle map[string]*idleCosts
+	var cpuShares, ramShares, gpuShares map[string]map[float64]float64
+	if opts.IncludeIdle || opts.ShareIdle {
+		idle = computeIdleCosts(undedicated, discount, opts.Pricing, opts.IdleByNode)
+	}
+	if opts.ShareIdle {
+		cpuShares = make(map[string]map[float64]float64)
+		ramShares = make(map[string]map[float64]float64)
+		gpuShares = make(map[string]map[float64]float64)
+		for key, ic := range idle {
+			cpuShares[key] = shareIdleCoefficients(ic.CPUCostVector, ic.cpuCharged)
+			ramShares[key] = shareIdleCoefficients(ic.RAMCostVector, ic.ramCharged)
+			gpuShares[key] = shareIdleCoefficients(ic.GPUCostVector, ic.gpuCharged)
+		}
+	}
+
+	levels := parseAggregationLevels(aggregationField, aggregationSubField)
 	aggregations := make(map[string]*Aggregation)
+	shared := &Aggregation{}
 	for _, costDatum := range costData {
-		if aggregationField == "cluster" {
-			aggregationHelper(costDatum, aggregationField, aggregationSubField, costDatum.ClusterID, aggregations, discount)
-		} else if aggregationField == "namespace" {
-			aggregationHelper(costDatum, aggregationField, aggregationSubField, costDatum.Namespace, aggregations, discount)
-		} else if aggregationField == "service" {
-			if len(costDatum.Services) > 0 {
-				aggregationHelper(costDatum, aggregationField, aggregationSubField, costDatum.Services[0], aggregations, discount)
-			}
-		} else if aggregationField == "deployment" {
-			if len(costDatum.Deployments) > 0 {
-				aggregationHelper(costDatum, aggregationField, aggregationSubField, costDatum.Deployments[0], aggregations, discount)
-			}
-		} else if aggregationField == "label" {
-			if costDatum.Labels != nil {
-				if subfieldName, ok := costDatum.Labels[aggregationSubField]; ok {
-					aggregationHelper(costDatum, aggregationField, aggregationSubField, subfieldName, aggregations, discount)
-				}
-			}
+		tenant := ""
+		if opts.DedicatedNodes {
+			tenant = dedicatedTenant(costDatum)
+		}
+		cpuv, ramv, gpuv, pvvs := getPriceVectors(costDatum, discount, opts.Pricing)
+		if opts.ShareIdle && tenant == "" {
+			key := idleGroupKey(costDatum, opts.IdleByNode)
+			scaleVectors(cpuv, cpuShares[key])
+			scaleVectors(ramv, ramShares[key])
+			scaleVectors(gpuv, gpuShares[key])
+		}
+		cpuWaste, ramWaste := getWasteVectors(costDatum, discount, opts.Pricing)
+		vectors := &costVectors{
+			CPU:      cpuv,
+			RAM:      ramv,
+			GPU:      gpuv,
+			PVs:      pvvs,
+			CPUWaste: cpuWaste,
+			RAMWaste: ramWaste,
+		}
+		if tenant != "" {
+			key, properties := dedicatedAggregationKey(tenant, levels, opts.TenantLevel)
+			aggregationHelper(costDatum, aggregationField, aggregationSubField, key, properties, aggregations, vectors)
+		} else if opts.Shared.IsSharedResource(costDatum) {
+			mergeVectors(costDatum, shared, vectors)
+		} else if key, properties, ok := aggregationKeys(costDatum, levels); ok {
+			aggregationHelper(costDatum, aggregationField, aggregationSubField, key, properties, aggregations, vectors)
 		}
 	}
+	if opts.DedicatedNodes {
+		chargeDedicatedNodes(dedicated, tenants, opts.DedicatedNodeCosts, discount, aggregations, aggregationField, aggregationSubField, levels, opts.TenantLevel)
+	}
+
+	if opts.Shared != nil {
+		targets := make([]*Aggregation, 0, len(aggregations))
+		for _, agg := range aggregations {
+			targets = append(targets, agg)
+		}
+		distributeSharedCosts(targets, shared, opts.Shared.Split)
+	}
+
+	for _, ic := range idle {
+		if opts.ShareIdle && totalVector(ic.CPUCostVector)+totalVector(ic.RAMCostVector)+totalVector(ic.GPUCostVector) == 0 {
+			continue // everything was shared
+		}
+		node := ""
+		if opts.IdleByNode {
+			node = ic.Node
+		}
+		key := idleAggregationKey(ic.Cluster, node)
+		aggregations[key] = &Aggregation{
+			Aggregator:         aggregationField,
+			AggregatorSubField: aggregationSubField,
+			Environment:        key,
+			Cluster:            ic.Cluster,
+			CPUCostVector:      ic.CPUCostVector,
+			RAMCostVector:      ic.RAMCostVector,
+			GPUCostVector:      ic.GPUCostVector,
+		}
+	}
+
 	for _, agg := range aggregations {
 		agg.CPUCost = totalVector(agg.CPUCostVector)
 		agg.RAMCost = totalVector(agg.RAMCostVector)
 		agg.GPUCost = totalVector(agg.GPUCostVector)
 		agg.PVCost = totalVector(agg.PVCostVector)
-		agg.TotalCost = agg.CPUCost + agg.RAMCost + agg.GPUCost + agg.PVCost
+		agg.NetworkCost = totalVector(agg.NetworkCostVector)
+		agg.NetworkZoneCost = totalVector(agg.NetworkZoneVector)
+		agg.NetworkRegionCost = totalVector(agg.NetworkRegionVector)
+		agg.NetworkInternetCost = totalVector(agg.NetworkInternetVector)
+		agg.TotalCost = agg.CPUCost + agg.RAMCost + agg.GPUCost + agg.PVCost + agg.NetworkCost
+		computeEfficiency(agg)
 	}
 	return aggregations
 }
 
-func aggregationHelper(costDatum *CostData, aggregator string, aggregatorSubField string, key string, aggregations map[string]*Aggregation, discount float64) {
+// AggregationKeySeparator joins the values of each level of a multi-level aggregation into a single key. Kubernetes
+// names and label values cannot contain it, unlike "/" which appears in pod keys and label names. Clients should
+// still read the value of each level from the aggregation's Properties rather than split the key.
+const AggregationKeySeparator = "|"
+
+// UnallocatedKey is the key under which containers with no value for the aggregation field (e.g. without the label,
+// service or deployment) are aggregated, so that aggregated totals reconcile with the unaggregated cost data.
+const UnallocatedKey = "__unallocated__"
+
+// aggregationLevel is one field of a possibly multi-level aggregation, e.g. "namespace" or "label:team".
+type aggregationLevel struct {
+	Field    string
+	SubField string
+}
+
+func (l aggregationLevel) String() string {
+	if l.SubField == "" {
+		return l.Field
+	}
+	return l.Field + ":" + l.SubField
+}
+
+// parseAggregationLevels splits a comma separated aggregation such as "cluster,namespace,label:team" into its levels.
+// A label or annotation level given without an inline subfield uses aggregationSubField.
+func parseAggregationLevels(aggregationField string, aggregationSubField string) []aggregationLevel {
+	var levels []aggregationLevel
+	for _, f := range strings.Split(aggregationField, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		level := aggregationLevel{
+			Field: f,
+		}
+		if fs := strings.SplitN(f, ":", 2); len(fs) == 2 {
+			level.Field = fs[0]
+			level.SubField = fs[1]
+		} else if f == "label" || f == "annotation" {
+			level.SubField = aggregationSubField
+		}
+		levels = append(levels, level)
+	}
+	return levels
+}
+
+// aggregationKeys returns the key a container is aggregated under, made of its value at every level joined by
+// AggregationKeySeparator. For multi-level aggregations, the value at each level is also returned by level name.
+// Levels the container has no value for are keyed by UnallocatedKey.
+func aggregationKeys(costDatum *CostData, levels []aggregationLevel) (string, map[string]string, bool) {
+	if len(levels) == 0 {
+		return "", nil, false
+	}
+	values := make([]string, 0, len(levels))
+	var properties map[string]string
+	if len(levels) > 1 {
+		properties = make(map[string]string)
+	}
+	for _, level := range levels {
+		value, ok := aggregationKey(costDatum, level.Field, level.SubField)
+		if !ok {
+			value = UnallocatedKey
+		}
+		values = append(values, value)
+		if properties != nil {
+			properties[level.String()] = value
+		}
+	}
+	return strings.Join(values, AggregationKeySeparator), properties, true
+}
+
+// aggregationKey returns the key a container is aggregated under, or false if the container has no value for the
+// aggregation field.
+func aggregationKey(costDatum *CostData, aggregationField string, aggregationSubField string) (string, bool) {
+	if aggregationField == "cluster" {
+		return costDatum.ClusterID, true
+	} else if aggregationField == "namespace" {
+		return costDatum.Namespace, true
+	} else if aggregationField == "service" {
+		if len(costDatum.Services) > 0 {
+			return costDatum.Services[0], true
+		}
+	} else if aggregationField == "deployment" {
+		if len(costDatum.Deployments) > 0 {
+			return costDatum.Deployments[0], true
+		}
+	} else if aggregationField == "statefulset" {
+		if len(costDatum.Statefulsets) > 0 {
+			return costDatum.Statefulsets[0], true
+		}
+	} else if aggregationField == "daemonset" {
+		if len(costDatum.Daemonsets) > 0 {
+			return costDatum.Daemonsets[0], true
+		}
+	} else if aggregationField == "job" {
+		if len(costDatum.Jobs) > 0 {
+			return costDatum.Jobs[0], true
+		}
+	} else if aggregationField == "cronjob" {
+		if len(costDatum.CronJobs) > 0 {
+			return costDatum.CronJobs[0], true
+		}
+	} else if aggregationField == "pod" {
+		// Pods and containers are named namespace/pod[/container] as kubectl does, whatever the level separator.
+		return costDatum.Namespace + "/" + costDatum.PodName, true
+	} else if aggregationField == "container" {
+		return costDatum.Namespace + "/" + costDatum.PodName + "/" + costDatum.Name, true
+	} else if aggregationField == "node" {
+		return costDatum.NodeName, true
+	} else if aggregationField == "owner" {
+		return costDatum.Owner, costDatum.Owner != ""
+	} else if aggregationField == "department" {
+		return costDatum.Department, costDatum.Department != ""
+	} else if aggregationField == "product" {
+		return costDatum.Product, costDatum.Product != ""
+	} else if aggregationField == "environment" {
+		return costDatum.Environment, costDatum.Environment != ""
+	} else if aggregationField == "label" {
+		if costDatum.Labels != nil {
+			if subfieldName, ok := costDatum.Labels[aggregationSubField]; ok {
+				return subfieldName, true
+			}
+		}
+	} else if aggregationField == "annotation" {
+		if costDatum.Annotations != nil {
+			if subfieldName, ok := costDatum.Annotations[aggregationSubField]; ok {
+				return subfieldName, true
+			}
+		}
+	}
+	return "", false
+}
+
+func aggregationHelper(costDatum *CostData, aggregator string, aggregatorSubField string, key string, properties map[string]string, aggregations map[string]*Aggregation, vectors *costVectors) {
 	if _, ok := aggregations[key]; !ok {
 		agg := &Aggregation{}
 		agg.Aggregator = aggregator
 		agg.AggregatorSubField = aggregatorSubField
 		agg.Environment = key
 		agg.Cluster = costDatum.ClusterID
+		agg.Properties = properties
 		aggregations[key] = agg
 	}
-	mergeVectors(costDatum, aggregations[key], discount)
+	mergeVectors(costDatum, aggregations[key], vectors)
 }
 
-func mergeVectors(costDatum *CostData, aggregation *Aggregation, discount float64) {
+// costVectors holds the priced vectors of a single container.
+type costVectors struct {
+	CPU      []*Vector
+	RAM      []*Vector
+	GPU      []*Vector
+	PVs      [][]*Vector
+	CPUWaste []*Vector
+	RAMWaste []*Vector
+}
+
+func mergeVectors(costDatum *CostData, aggregation *Aggregation, vectors *costVectors) {
 	aggregation.CPUAllocation = addVectors(costDatum.CPUAllocation, aggregation.CPUAllocation)
 	aggregation.RAMAllocation = addVectors(costDatum.RAMAllocation, aggregation.RAMAllocation)
 	aggregation.GPUAllocation = addVectors(costDatum.GPUReq, aggregation.GPUAllocation)
+	aggregation.CPURequested = addVectors(costDatum.CPUReq, aggregation.CPURequested)
+	aggregation.CPUUsed = addVectors(costDatum.CPUUsed, aggregation.CPUUsed)
+	aggregation.RAMRequested = addVectors(costDatum.RAMReq, aggregation.RAMRequested)
+	aggregation.RAMUsed = addVectors(costDatum.RAMUsed, aggregation.RAMUsed)
 
-	cpuv, ramv, gpuv, pvvs := getPriceVectors(costDatum, discount)
-	aggregation.CPUCostVector = addVectors(cpuv, aggregation.CPUCostVector)
-	aggregation.RAMCostVector = addVectors(ramv, aggregation.RAMCostVector)
-	aggregation.GPUCostVector = addVectors(gpuv, aggregation.GPUCostVector)
-	for _, vectorList := range pvvs {
+	aggregation.CPUCostVector = addVectors(vectors.CPU, aggregation.CPUCostVector)
+	aggregation.RAMCostVector = addVectors(vectors.RAM, aggregation.RAMCostVector)
+	aggregation.GPUCostVector = addVectors(vectors.GPU, aggregation.GPUCostVector)
+	aggregation.CPUWasteVector = addVectors(vectors.CPUWaste, aggregation.CPUWasteVector)
+	aggregation.RAMWasteVector = addVectors(vectors.RAMWaste, aggregation.RAMWasteVector)
+	for _, vectorList := range vectors.PVs {
 		aggregation.PVCostVector = addVectors(aggregation.PVCostVector, vectorList)
 	}
+
+	aggregation.NetworkCostVector = addVectors(costDatum.NetworkData, aggregation.NetworkCostVector)
+	if costDatum.NetworkTiers != nil {
+		aggregation.NetworkZoneVector = addVectors(costDatum.NetworkTiers.ZoneCost, aggregation.NetworkZoneVector)
+		aggregation.NetworkRegionVector = addVectors(costDatum.NetworkTiers.RegionCost, aggregation.NetworkRegionVector)
+		aggregation.NetworkInternetVector = addVectors(costDatum.NetworkTiers.InternetCost, aggregation.NetworkInternetVector)
+	}
 }
 
-func getPriceVectors(costDatum *CostData, discount float64) ([]*Vector, []*Vector, []*Vector, [][]*Vector) {
+func getPriceVectors(costDatum *CostData, discount float64, rules *PricingRules) ([]*Vector, []*Vector, []*Vector, [][]*Vector) {
+	multiplier := rules.multiplier(costDatum)
 	cpuv := make([]*Vector, 0, len(costDatum.CPUAllocation))
 	for _, val := range costDatum.CPUAllocation {
 		cost, _ := strconv.ParseFloat(costDatum.NodeData.VCPUCost, 64)
 		cpuv = append(cpuv, &Vector{
 			Timestamp: math.Round(val.Timestamp/10) * 10,
-			Value:     val.Value * cost * (1 - discount),
+			Value:     val.Value * cost * (1 - discount) * multiplier,
 		})
 	}
 	ramv := make([]*Vector, 0, len(costDatum.RAMAllocation))
@@ -99,7 +374,7 @@ func getPriceVectors(costDatum *CostData, discount float64) ([]*Vector, []*Vecto
 		cost, _ := strconv.ParseFloat(costDatum.NodeData.RAMCost, 64)
 		ramv = append(ramv, &Vector{
 			Timestamp: math.Round(val.Timestamp/10) * 10,
-			Value:     (val.Value / 1024 / 1024 / 1024) * cost * (1 - discount),
+			Value:     (val.Value / 1024 / 1024 / 1024) * cost * (1 - discount) * multiplier,
 		})
 	}
 	gpuv := make([]*Vector, 0, len(costDatum.GPUReq))
@@ -107,7 +382,7 @@ func getPriceVectors(costDatum *CostData, discount float64) ([]*Vector, []*Vecto
 		cost, _ := strconv.ParseFloat(costDatum.NodeData.GPUCost, 64)
 		gpuv = append(gpuv, &Vector{
 			Timestamp: math.Round(val.Timestamp/10) * 10,
-			Value:     val.Value * cost * (1 - discount),
+			Value:     val.Value * cost * (1 - discount) * multiplier,
 		})
 	}
 	pvvs := make([][]*Vector, 0, len(costDatum.PVCData))
@@ -0,0 +1,92 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestParseAggregationLevels(t *testing.T) {
+	cases := []struct {
+		field    string
+		subField string
+		expected []aggregationLevel
+	}{
+		{"namespace", "", []aggregationLevel{{Field: "namespace"}}},
+		{"label", "team", []aggregationLevel{{Field: "label", SubField: "team"}}},
+		{"annotation", "example.com/team", []aggregationLevel{{Field: "annotation", SubField: "example.com/team"}}},
+		{"cluster, label:app", "team", []aggregationLevel{{Field: "cluster"}, {Field: "label", SubField: "app"}}},
+		{"namespace,annotation", "owner", []aggregationLevel{{Field: "namespace"}, {Field: "annotation", SubField: "owner"}}},
+	}
+	for _, c := range cases {
+		levels := parseAggregationLevels(c.field, c.subField)
+		if len(levels) != len(c.expected) {
+			t.Errorf("%s: got levels %v, expected %v", c.field, levels, c.expected)
+			continue
+		}
+		for i := range levels {
+			if levels[i] != c.expected[i] {
+				t.Errorf("%s: got levels %v, expected %v", c.field, levels, c.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestAggregateCostModelByAnnotation(t *testing.T) {
+	node := testNode(4, 4)
+	a := testContainer("cluster-one", node, "node-a", "ns1", 1, 1)
+	a.Annotations = map[string]string{"example.com/team": "payments"}
+	b := testContainer("cluster-one", node, "node-a", "ns2", 2, 1)
+	b.Annotations = map[string]string{"example.com/team": "payments"}
+	c := testContainer("cluster-one", node, "node-a", "ns3", 1, 1)
+	costData := map[string]*CostData{"a": a, "b": b, "c": c}
+
+	agg := AggregateCostModel(costData, "annotation", "example.com/team", nil)
+	if len(agg) != 2 {
+		t.Fatalf("Got %d aggregations, expected payments and %s", len(agg), UnallocatedKey)
+	}
+	if !approxEqual(agg["payments"].CPUCost, 3) {
+		t.Errorf("CPU cost of payments is %f, expected 3", agg["payments"].CPUCost)
+	}
+	if !approxEqual(agg[UnallocatedKey].CPUCost, 1) {
+		t.Errorf("CPU cost of %s is %f, expected 1", UnallocatedKey, agg[UnallocatedKey].CPUCost)
+	}
+
+	agg = AggregateCostModel(costData, "namespace,annotation:example.com/team", "", nil)
+	if _, ok := agg["ns2|payments"]; !ok {
+		t.Errorf("Missing aggregation ns2|payments in %v", agg)
+	}
+	if _, ok := agg["ns3|"+UnallocatedKey]; !ok {
+		t.Errorf("Missing aggregation ns3|%s in %v", UnallocatedKey, agg)
+	}
+}
+
+func TestAggregationKeysPodAndContainer(t *testing.T) {
+	costDatum := testContainer("cluster-one", testNode(4, 4), "node-a", "ns1", 1, 1)
+	costDatum.PodName = "web-1"
+	costDatum.Name = "app"
+	cases := []struct {
+		aggregation string
+		key         string
+		properties  map[string]string
+	}{
+		{"pod", "ns1/web-1", nil},
+		{"container", "ns1/web-1/app", nil},
+		{"namespace,pod", "ns1|ns1/web-1", map[string]string{"namespace": "ns1", "pod": "ns1/web-1"}},
+		{"cluster,container", "cluster-one|ns1/web-1/app", map[string]string{"cluster": "cluster-one", "container": "ns1/web-1/app"}},
+	}
+	for _, c := range cases {
+		key, properties, ok := aggregationKeys(costDatum, parseAggregationLevels(c.aggregation, ""))
+		if !ok || key != c.key {
+			t.Errorf("%s: key is %q, expected %q", c.aggregation, key, c.key)
+		}
+		if len(properties) != len(c.properties) {
+			t.Errorf("%s: properties are %v, expected %v", c.aggregation, properties, c.properties)
+		}
+		for k, v := range c.properties {
+			if properties[k] != v {
+				t.Errorf("%s: properties are %v, expected %v", c.aggregation, properties, c.properties)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package costmodel
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+const (
+	// AllocationRequest allocates what containers requested, for predictable chargeback.
+	AllocationRequest = "request"
+	// AllocationUsage allocates what containers used, for showback.
+	AllocationUsage = "usage"
+	// AllocationMax allocates the larger of request and usage. This is the default.
+	AllocationMax = "max"
+	// AllocationWeighted allocates a blend of request and usage.
+	AllocationWeighted = "weighted"
+)
+
+// AllocationStrategy decides how much CPU and RAM is allocated to a container from its requests and usage.
+type AllocationStrategy struct {
+	Type string
+	// RequestWeight is the share of the allocation coming from requests with AllocationWeighted, the rest coming
+	// from usage.
+	RequestWeight float64
+}
+
+// NewAllocationStrategy returns the strategy of the given type. The weight is only read for AllocationWeighted and
+// defaults to 0.5.
+func NewAllocationStrategy(strategyType string, weight string) (*AllocationStrategy, error) {
+	s := &AllocationStrategy{
+		Type: strategyType,
+	}
+	switch strategyType {
+	case AllocationRequest, AllocationUsage, AllocationMax:
+	case AllocationWeighted:
+		s.RequestWeight = 0.5
+		if weight != "" {
+			w, err := strconv.ParseFloat(weight, 64)
+			if err != nil {
+				return nil, err
+			}
+			if w < 0 || w > 1 {
+				return nil, fmt.Errorf("Invalid allocation weight %s, expected a value between 0 and 1", weight)
+			}
+			s.RequestWeight = w
+		}
+	default:
+		return nil, fmt.Errorf("Invalid allocation strategy '%s', expected one of request, usage, max or weighted", strategyType)
+	}
+	return s, nil
+}
+
+func (s *AllocationStrategy) isMax() bool {
+	return s == nil || s.Type == AllocationMax
+}
+
+// allocate returns the allocation for a request and a usage, either of which is 0 when missing.
+func (s *AllocationStrategy) allocate(request float64, used float64) float64 {
+	if s.isMax() {
+		return math.Max(request, used)
+	}
+	switch s.Type {
+	case AllocationRequest:
+		return request
+	case AllocationUsage:
+		return used
+	case AllocationWeighted:
+		return s.RequestWeight*request + (1-s.RequestWeight)*used
+	}
+	return math.Max(request, used)
+}
+
+// ApplyAllocationStrategy recomputes the CPU and RAM allocation of every container with the given strategy, for
+// callers wanting a different strategy than the one the cost data was computed with.
+func ApplyAllocationStrategy(costData map[string]*CostData, strategy *AllocationStrategy) {
+	for _, costDatum := range costData {
+		costDatum.CPUAllocation = getContainerAllocation(costDatum.CPUReq, costDatum.CPUUsed, strategy)
+		costDatum.RAMAllocation = getContainerAllocation(costDatum.RAMReq, costDatum.RAMUsed, strategy)
+	}
+}
@@ -0,0 +1,114 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestNewAllocationStrategy(t *testing.T) {
+	cases := []struct {
+		strategyType  string
+		weight        string
+		valid         bool
+		requestWeight float64
+	}{
+		{AllocationRequest, "", true, 0},
+		{AllocationUsage, "", true, 0},
+		{AllocationMax, "", true, 0},
+		{AllocationRequest, "invalid", true, 0},
+		{AllocationWeighted, "", true, 0.5},
+		{AllocationWeighted, "0.25", true, 0.25},
+		{AllocationWeighted, "1", true, 1},
+		{AllocationWeighted, "1.5", false, 0},
+		{AllocationWeighted, "-0.1", false, 0},
+		{AllocationWeighted, "half", false, 0},
+		{"min", "", false, 0},
+		{"", "", false, 0},
+	}
+	for _, c := range cases {
+		s, err := NewAllocationStrategy(c.strategyType, c.weight)
+		if !c.valid {
+			if err == nil {
+				t.Errorf("Expected an error for strategy %q with weight %q", c.strategyType, c.weight)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for strategy %q with weight %q: %s", c.strategyType, c.weight, err.Error())
+			continue
+		}
+		if s.Type != c.strategyType || s.RequestWeight != c.requestWeight {
+			t.Errorf("Strategy %q with weight %q parsed as %+v", c.strategyType, c.weight, *s)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	cases := []struct {
+		strategy *AllocationStrategy
+		request  float64
+		used     float64
+		expected float64
+	}{
+		{nil, 2, 1, 2},
+		{nil, 1, 2, 2},
+		{&AllocationStrategy{Type: AllocationMax}, 1, 3, 3},
+		{&AllocationStrategy{Type: AllocationRequest}, 2, 3, 2},
+		{&AllocationStrategy{Type: AllocationRequest}, 0, 3, 0},
+		{&AllocationStrategy{Type: AllocationUsage}, 2, 1, 1},
+		{&AllocationStrategy{Type: AllocationUsage}, 2, 0, 0},
+		{&AllocationStrategy{Type: AllocationWeighted, RequestWeight: 0.25}, 2, 1, 1.25},
+		{&AllocationStrategy{Type: AllocationWeighted, RequestWeight: 1}, 2, 1, 2},
+	}
+	for _, c := range cases {
+		if a := c.strategy.allocate(c.request, c.used); !approxEqual(a, c.expected) {
+			t.Errorf("Strategy %+v allocates %f for a request of %f and a usage of %f, expected %f", c.strategy, a, c.request, c.used, c.expected)
+		}
+	}
+}
+
+func TestGetContainerAllocation(t *testing.T) {
+	// Requests and usage are recorded at slightly different times, and each is missing at one timestamp.
+	vectors := func(values map[float64]float64) []*Vector {
+		var result []*Vector
+		for ts, v := range values {
+			result = append(result, &Vector{Timestamp: ts, Value: v})
+		}
+		return result
+	}
+	cases := []struct {
+		name     string
+		strategy *AllocationStrategy
+		expected []float64
+	}{
+		{"max", nil, []float64{2, 3, 1}},
+		{"request", &AllocationStrategy{Type: AllocationRequest}, []float64{2, 2, 0}},
+		{"usage", &AllocationStrategy{Type: AllocationUsage}, []float64{0, 3, 1}},
+		{"weighted", &AllocationStrategy{Type: AllocationWeighted, RequestWeight: 0.5}, []float64{1, 2.5, 0.5}},
+	}
+	for _, c := range cases {
+		req := vectors(map[float64]float64{3601: 2, 7202: 2})
+		used := vectors(map[float64]float64{7199: 3, 10800: 1})
+		allocation := getContainerAllocation(req, used, c.strategy)
+		if len(allocation) != len(c.expected) {
+			t.Errorf("%s: got %d allocation vectors, expected %d", c.name, len(allocation), len(c.expected))
+			continue
+		}
+		for i, v := range allocation {
+			if v.Timestamp != float64(3600*(i+1)) {
+				t.Errorf("%s: vector %d is at %f, expected %d", c.name, i, v.Timestamp, 3600*(i+1))
+			}
+			if !approxEqual(v.Value, c.expected[i]) {
+				t.Errorf("%s: allocation at %f is %f, expected %f", c.name, v.Timestamp, v.Value, c.expected[i])
+			}
+		}
+	}
+
+	// With max, a container without usage is allocated its requests as is.
+	req := testVectors(1, 2)
+	if allocation := getContainerAllocation(req, nil, nil); len(allocation) != 2 || allocation[1].Value != 2 {
+		t.Errorf("Max allocation without usage is %v, expected the requests", allocation)
+	}
+	if allocation := getContainerAllocation(req, nil, &AllocationStrategy{Type: AllocationUsage}); len(allocation) != 2 || allocation[1].Value != 0 {
+		t.Errorf("Usage allocation without usage is %v, expected zeros", allocation)
+	}
+}
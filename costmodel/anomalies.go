@@ -0,0 +1,204 @@
+package costmodel
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// AnomalyBaselineDays is the number of days before the evaluated day its cost is compared against.
+	AnomalyBaselineDays = 14
+	// AnomalyThreshold is the number of standard deviations above the baseline a day's cost must be to be anomalous.
+	AnomalyThreshold = 3.0
+
+	minAnomalyBaselineDays = 7
+	// minAnomalyStdDev is the smallest standard deviation assumed, relative to the baseline, so that a spike over a
+	// perfectly flat baseline is still detected.
+	minAnomalyStdDev  = 0.05
+	maxAnomalyLogSize = 1000
+)
+
+// Anomaly is a day on which the cost of an aggregation key spiked above its rolling baseline.
+type Anomaly struct {
+	Aggregator string  `json:"aggregator"`
+	Key        string  `json:"key"`
+	Day        string  `json:"day"`
+	Timestamp  float64 `json:"timestamp"`
+	Cost       float64 `json:"cost"`
+	Baseline   float64 `json:"baseline"`
+	StdDev     float64 `json:"stdDev"`
+	ZScore     float64 `json:"zScore"`
+	// Resource is the one of cpu, ram, gpu, pv or network whose cost increased the most over its own baseline.
+	Resource         string  `json:"resource"`
+	ResourceCost     float64 `json:"resourceCost"`
+	ResourceBaseline float64 `json:"resourceBaseline"`
+}
+
+// DetectAnomalies compares the cost of each aggregation on the last complete day before end with its cost over the
+// AnomalyBaselineDays before that. The aggregations must be built from hourly cost data ending at end, a UTC
+// midnight. Keys with less than a week of baseline are skipped.
+func DetectAnomalies(aggregations map[string]*Aggregation, aggregator string, end time.Time) ([]*Anomaly, error) {
+	if err := ComputeTimeSeries(aggregations, ResolutionDaily); err != nil {
+		return nil, err
+	}
+	day := float64(end.Unix()) - secondsPerDay
+	var anomalies []*Anomaly
+	for key, agg := range aggregations {
+		var current *CostTimeSeriesPoint
+		var baseline []*CostTimeSeriesPoint
+		for _, p := range agg.TimeSeries {
+			if p.Timestamp == day {
+				current = p
+			} else if p.Timestamp < day && p.Timestamp >= day-AnomalyBaselineDays*secondsPerDay {
+				baseline = append(baseline, p)
+			}
+		}
+		if current == nil || len(baseline) < minAnomalyBaselineDays {
+			continue
+		}
+
+		mean, stdDev := meanStdDev(baseline, func(p *CostTimeSeriesPoint) float64 { return p.TotalCost })
+		stdDev = math.Max(stdDev, mean*minAnomalyStdDev)
+		if stdDev == 0 || (current.TotalCost-mean)/stdDev < AnomalyThreshold {
+			continue
+		}
+		anomaly := &Anomaly{
+			Aggregator: aggregator,
+			Key:        key,
+			Day:        time.Unix(int64(day), 0).UTC().Format("2006-01-02"),
+			Timestamp:  day,
+			Cost:       current.TotalCost,
+			Baseline:   mean,
+			StdDev:     stdDev,
+			ZScore:     (current.TotalCost - mean) / stdDev,
+		}
+		increase := math.Inf(-1)
+		for _, resource := range anomalyResources {
+			resourceMean, _ := meanStdDev(baseline, resource.cost)
+			if resource.cost(current)-resourceMean > increase {
+				increase = resource.cost(current) - resourceMean
+				anomaly.Resource = resource.name
+				anomaly.ResourceCost = resource.cost(current)
+				anomaly.ResourceBaseline = resourceMean
+			}
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+	return anomalies, nil
+}
+
+var anomalyResources = []struct {
+	name string
+	cost func(p *CostTimeSeriesPoint) float64
+}{
+	{"cpu", func(p *CostTimeSeriesPoint) float64 { return p.CPUCost }},
+	{"ram", func(p *CostTimeSeriesPoint) float64 { return p.RAMCost }},
+	{"gpu", func(p *CostTimeSeriesPoint) float64 { return p.GPUCost }},
+	{"pv", func(p *CostTimeSeriesPoint) float64 { return p.PVCost }},
+	{"network", func(p *CostTimeSeriesPoint) float64 { return p.NetworkCost }},
+}
+
+func meanStdDev(points []*CostTimeSeriesPoint, value func(p *CostTimeSeriesPoint) float64) (float64, float64) {
+	mean := 0.0
+	for _, p := range points {
+		mean += value(p) / float64(len(points))
+	}
+	variance := 0.0
+	for _, p := range points {
+		variance += (value(p) - mean) * (value(p) - mean) / float64(len(points))
+	}
+	return mean, math.Sqrt(variance)
+}
+
+// AnomalyLog keeps the most recent anomalies detected, at most one per aggregator, key and day, persisted as JSON
+// next to the pricing config.
+type AnomalyLog struct {
+	mu        sync.RWMutex
+	path      string
+	anomalies []*Anomaly
+	seen      map[string]bool
+}
+
+// NewAnomalyLog loads the anomalies persisted at path, if any.
+func NewAnomalyLog(path string) (*AnomalyLog, error) {
+	l := &AnomalyLog{
+		path: path,
+		seen: make(map[string]bool),
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var anomalies []*Anomaly
+	if err := json.Unmarshal(b, &anomalies); err != nil {
+		return nil, err
+	}
+	l.add(anomalies)
+	return l, nil
+}
+
+func anomalyID(a *Anomaly) string {
+	return a.Aggregator + "," + a.Key + "," + a.Day
+}
+
+// Add records anomalies not already in the log, dropping the oldest ones beyond the log's capacity, and persists
+// the log if it changed.
+func (l *AnomalyLog) Add(anomalies []*Anomaly) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.add(anomalies) {
+		return nil
+	}
+	b, err := json.Marshal(l.anomalies)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(l.path, b, 0644)
+}
+
+// add records anomalies not already in the log and returns whether any was. The caller holds the lock.
+func (l *AnomalyLog) add(anomalies []*Anomaly) bool {
+	added := false
+	for _, a := range anomalies {
+		id := anomalyID(a)
+		if l.seen[id] {
+			continue
+		}
+		l.seen[id] = true
+		l.anomalies = append(l.anomalies, a)
+		added = true
+	}
+	sort.SliceStable(l.anomalies, func(i, j int) bool {
+		return l.anomalies[i].Timestamp > l.anomalies[j].Timestamp
+	})
+	for len(l.anomalies) > maxAnomalyLogSize {
+		delete(l.seen, anomalyID(l.anomalies[len(l.anomalies)-1]))
+		l.anomalies = l.anomalies[:len(l.anomalies)-1]
+	}
+	return added
+}
+
+// List returns the anomalies of days starting at or after since, most recent first. Empty aggregator or key match
+// any.
+func (l *AnomalyLog) List(since time.Time, aggregator string, key string) []*Anomaly {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	result := []*Anomaly{}
+	for _, a := range l.anomalies {
+		if a.Timestamp < float64(since.Unix()) {
+			continue
+		}
+		if (aggregator != "" && a.Aggregator != aggregator) || (key != "" && a.Key != key) {
+			continue
+		}
+		result = append(result, a)
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+package costmodel
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	end := time.Date(2019, 9, 16, 0, 0, 0, 0, time.UTC)
+	start := end.Add(-15 * 24 * time.Hour)
+	// flat costs one an hour, and spike three an hour on the last day, the day evaluated.
+	flat := func(h int) float64 { return 1 }
+	spike := func(h int) float64 {
+		if h > 14*24 {
+			return 3
+		}
+		return 1
+	}
+	aggregations := map[string]*Aggregation{
+		"steady": {
+			CPUCostVector: hourlyCosts(start, end, flat),
+			RAMCostVector: hourlyCosts(start, end, flat),
+		},
+		// The RAM cost spikes while the CPU cost stays flat.
+		"ram": {
+			CPUCostVector: hourlyCosts(start, end, flat),
+			RAMCostVector: hourlyCosts(start, end, spike),
+		},
+		// 2.4 above a baseline of 48, the 5% minimum standard deviation of a flat baseline.
+		"below-threshold": {
+			CPUCostVector: hourlyCosts(start, end, func(h int) float64 {
+				if h > 14*24 {
+					return 1.1
+				}
+				return 1
+			}),
+			RAMCostVector: hourlyCosts(start, end, flat),
+		},
+		// Seven and six days of baseline before the day evaluated.
+		"week": {
+			CPUCostVector: hourlyCosts(end.Add(-8*24*time.Hour), end, func(h int) float64 { return spike(h + 7*24) }),
+		},
+		"new": {
+			CPUCostVector: hourlyCosts(end.Add(-7*24*time.Hour), end, func(h int) float64 { return spike(h + 8*24) }),
+		},
+	}
+
+	anomalies, err := DetectAnomalies(aggregations, "namespace", end)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	found := make(map[string]*Anomaly)
+	for _, a := range anomalies {
+		found[a.Key] = a
+	}
+	if len(found) != 2 || found["ram"] == nil || found["week"] == nil {
+		t.Fatalf("Got anomalies %v, expected ram and week", found)
+	}
+	a := found["ram"]
+	if a.Aggregator != "namespace" || a.Day != "2019-09-15" || a.Timestamp != float64(end.Unix()-secondsPerDay) {
+		t.Errorf("Anomaly of ram is by %s on %s at %f, expected namespace on 2019-09-15", a.Aggregator, a.Day, a.Timestamp)
+	}
+	if !approxEqual(a.Cost, 96) || !approxEqual(a.Baseline, 48) || !approxEqual(a.StdDev, 2.4) || !approxEqual(a.ZScore, 20) {
+		t.Errorf("Anomaly of ram costs %f over a baseline of %f, standard deviation %f and z-score %f, expected 96, 48, 2.4 and 20", a.Cost, a.Baseline, a.StdDev, a.ZScore)
+	}
+	if a.Resource != "ram" || !approxEqual(a.ResourceCost, 72) || !approxEqual(a.ResourceBaseline, 24) {
+		t.Errorf("Anomaly of ram is attributed to %s costing %f over %f, expected ram costing 72 over 24", a.Resource, a.ResourceCost, a.ResourceBaseline)
+	}
+	if a := found["week"]; a.Resource != "cpu" {
+		t.Errorf("Anomaly of week is attributed to %s, expected cpu", a.Resource)
+	}
+}
+
+func TestAnomalyLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "anomalies")
+	if err != nil {
+		t.Fatalf("Error creating a temporary directory: %s", err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "anomalies.json")
+
+	l, err := NewAnomalyLog(path)
+	if err != nil {
+		t.Fatalf("Error creating the anomaly log: %s", err.Error())
+	}
+	day := func(d int) float64 { return float64(time.Date(2019, 9, d, 0, 0, 0, 0, time.UTC).Unix()) }
+	err = l.Add([]*Anomaly{
+		{Aggregator: "namespace", Key: "ns1", Day: "2019-09-14", Timestamp: day(14)},
+		{Aggregator: "namespace", Key: "ns2", Day: "2019-09-15", Timestamp: day(15)},
+		{Aggregator: "label:team", Key: "ns1", Day: "2019-09-15", Timestamp: day(15)},
+	})
+	if err != nil {
+		t.Fatalf("Error adding anomalies: %s", err.Error())
+	}
+	// The same key and day is only logged once.
+	if err := l.Add([]*Anomaly{{Aggregator: "namespace", Key: "ns1", Day: "2019-09-14", Timestamp: day(14), Cost: 1}}); err != nil {
+		t.Fatalf("Error adding anomalies: %s", err.Error())
+	}
+
+	// The log survives a restart.
+	l, err = NewAnomalyLog(path)
+	if err != nil {
+		t.Fatalf("Error loading the anomaly log: %s", err.Error())
+	}
+	since := time.Date(2019, 9, 1, 0, 0, 0, 0, time.UTC)
+	if anomalies := l.List(since, "", ""); len(anomalies) != 3 || anomalies[2].Key != "ns1" || anomalies[2].Cost != 0 {
+		t.Errorf("Got anomalies %v, expected 3, most recent first", anomalies)
+	}
+	if anomalies := l.List(since, "namespace", ""); len(anomalies) != 2 {
+		t.Errorf("Got %d namespace anomalies, expected 2", len(anomalies))
+	}
+	if anomalies := l.List(since, "", "ns1"); len(anomalies) != 2 {
+		t.Errorf("Got %d anomalies of ns1, expected 2", len(anomalies))
+	}
+	if anomalies := l.List(time.Date(2019, 9, 15, 0, 0, 0, 0, time.UTC), "", ""); len(anomalies) != 2 {
+		t.Errorf("Got %d anomalies since the 15th, expected 2", len(anomalies))
+	}
+	if err := l.Add([]*Anomaly{{Aggregator: "namespace", Key: "ns1", Day: "2019-09-14", Timestamp: day(14)}}); err != nil {
+		t.Fatalf("Error adding anomalies: %s", err.Error())
+	}
+	if anomalies := l.List(since, "", ""); len(anomalies) != 3 {
+		t.Errorf("Got %d anomalies after adding a reloaded one again, expected 3", len(anomalies))
+	}
+}
@@ -0,0 +1,330 @@
+package costmodel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// BudgetAlertActual alerts that month-to-date spend crossed a budget threshold.
+	BudgetAlertActual = "actual"
+	// BudgetAlertForecast alerts that spend projected to the end of the month crosses a budget threshold.
+	BudgetAlertForecast = "forecast"
+)
+
+// BudgetRunRateWindow is the window over which the hourly run-rate projecting a budget's monthly spend is averaged.
+const BudgetRunRateWindow = 24 * time.Hour
+
+var defaultBudgetThresholds = []float64{0.8, 1.0}
+
+var budgetWebhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// Budget is a monthly amount to spend on one aggregation key, such as a namespace, a label value or a cluster.
+type Budget struct {
+	Aggregator    string  `json:"aggregator"` // e.g. "namespace", "label:team" or "cluster"
+	Key           string  `json:"key"`
+	MonthlyAmount float64 `json:"monthlyAmount"`
+	// Thresholds are the fractions of the monthly amount to alert at, 0.8 and 1 by default.
+	Thresholds []float64 `json:"thresholds,omitempty"`
+	// WebhookURLs receive the alerts of this budget, in addition to those in CustomPricing.BudgetWebhookURLs.
+	WebhookURLs []string `json:"webhookURLs,omitempty"`
+	// Alerted is the highest threshold alerted at during AlertedMonth, e.g. "2019-09", by alert type. It is persisted
+	// with the budget so that each threshold alerts once a month, even across restarts.
+	AlertedMonth string             `json:"alertedMonth,omitempty"`
+	Alerted      map[string]float64 `json:"alerted,omitempty"`
+}
+
+// BudgetStatus is a budget along with its spend as of its last evaluation.
+type BudgetStatus struct {
+	*Budget
+	EvaluatedAt          string       `json:"evaluatedAt,omitempty"`
+	MonthToDate          *MonthToDate `json:"monthToDate,omitempty"`
+	Utilization          float64      `json:"utilization"`
+	ProjectedUtilization float64      `json:"projectedUtilization"`
+}
+
+// BudgetAlert is the JSON payload posted to webhooks when spend crosses a budget threshold.
+type BudgetAlert struct {
+	Type                 string  `json:"type"` // BudgetAlertActual or BudgetAlertForecast
+	Aggregator           string  `json:"aggregator"`
+	Key                  string  `json:"key"`
+	Month                string  `json:"month"`
+	MonthlyAmount        float64 `json:"monthlyAmount"`
+	Threshold            float64 `json:"threshold"`
+	MonthToDateCost      float64 `json:"monthToDateCost"`
+	ProjectedCost        float64 `json:"projectedCost"`
+	Utilization          float64 `json:"utilization"`
+	ProjectedUtilization float64 `json:"projectedUtilization"`
+	Timestamp            string  `json:"timestamp"`
+	Message              string  `json:"message"`
+
+	webhookURLs []string
+}
+
+// BudgetStore holds the budgets, persisted as JSON next to the pricing config, and the state of their evaluation.
+type BudgetStore struct {
+	mu       sync.RWMutex
+	path     string
+	budgets  []*Budget
+	statuses map[string]*BudgetStatus
+}
+
+// NewBudgetStore loads the budgets persisted at path, if any.
+func NewBudgetStore(path string) (*BudgetStore, error) {
+	s := &BudgetStore{
+		path:     path,
+		statuses: make(map[string]*BudgetStatus),
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.budgets); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func budgetID(aggregator string, key string) string {
+	return aggregator + "," + key
+}
+
+// List returns every budget with its last evaluated status.
+func (s *BudgetStore) List() []*BudgetStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]*BudgetStatus, 0, len(s.budgets))
+	for _, b := range s.budgets {
+		if status, ok := s.statuses[budgetID(b.Aggregator, b.Key)]; ok {
+			result = append(result, status)
+		} else {
+			result = append(result, &BudgetStatus{Budget: b})
+		}
+	}
+	return result
+}
+
+// Aggregators returns the distinct aggregators budgets are defined on.
+func (s *BudgetStore) Aggregators() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	seen := make(map[string]bool)
+	var aggregators []string
+	for _, b := range s.budgets {
+		if !seen[b.Aggregator] {
+			seen[b.Aggregator] = true
+			aggregators = append(aggregators, b.Aggregator)
+		}
+	}
+	sort.Strings(aggregators)
+	return aggregators
+}
+
+// Validate checks that the budget has an aggregator, a key, a positive monthly amount and positive thresholds.
+func (b *Budget) Validate() error {
+	if b.Aggregator == "" || b.Key == "" {
+		return fmt.Errorf("Budget requires an aggregator and a key")
+	}
+	if b.MonthlyAmount <= 0 {
+		return fmt.Errorf("Invalid monthly amount %f for budget %s, expected a positive value", b.MonthlyAmount, b.Key)
+	}
+	for _, t := range b.Thresholds {
+		if t <= 0 {
+			return fmt.Errorf("Invalid threshold %f for budget %s, expected a positive fraction of the monthly amount", t, b.Key)
+		}
+	}
+	return nil
+}
+
+// Set creates the budget, or replaces the one with the same aggregator and key, and persists the budgets. A replaced
+// budget alerts again at every threshold it crosses.
+func (s *BudgetStore) Set(budget *Budget) error {
+	if err := budget.Validate(); err != nil {
+		return err
+	}
+	if len(budget.Thresholds) == 0 {
+		budget.Thresholds = defaultBudgetThresholds
+	}
+	budget.AlertedMonth = ""
+	budget.Alerted = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := budgetID(budget.Aggregator, budget.Key)
+	budgets := make([]*Budget, 0, len(s.budgets)+1)
+	for _, b := range s.budgets {
+		if budgetID(b.Aggregator, b.Key) != id {
+			budgets = append(budgets, b)
+		}
+	}
+	budgets = append(budgets, budget)
+	if err := s.save(budgets); err != nil {
+		return err
+	}
+	s.budgets = budgets
+	delete(s.statuses, id)
+	return nil
+}
+
+// Delete removes the budget of the aggregator and key, and persists the budgets.
+func (s *BudgetStore) Delete(aggregator string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := budgetID(aggregator, key)
+	budgets := make([]*Budget, 0, len(s.budgets))
+	for _, b := range s.budgets {
+		if budgetID(b.Aggregator, b.Key) != id {
+			budgets = append(budgets, b)
+		}
+	}
+	if len(budgets) == len(s.budgets) {
+		return fmt.Errorf("No budget for %s %s", aggregator, key)
+	}
+	if err := s.save(budgets); err != nil {
+		return err
+	}
+	s.budgets = budgets
+	delete(s.statuses, id)
+	return nil
+}
+
+func (s *BudgetStore) save(budgets []*Budget) error {
+	b, err := json.Marshal(budgets)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path, b, 0644)
+}
+
+// Evaluate updates the status of the budgets of the aggregator from the month-to-date costs of its keys, and returns
+// the alerts of thresholds crossed since the last evaluation. Keys without costs have spent nothing yet. The budgets
+// are persisted when they alert, and the alerts are returned even if that fails.
+func (s *BudgetStore) Evaluate(aggregator string, costs map[string]*MonthToDate, webhookURLs []string, now time.Time) ([]*BudgetAlert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	month := now.UTC().Format("2006-01")
+
+	var alerts []*BudgetAlert
+	budgets := make([]*Budget, 0, len(s.budgets))
+	for _, b := range s.budgets {
+		if b.Aggregator != aggregator {
+			budgets = append(budgets, b)
+			continue
+		}
+		mtd, ok := costs[b.Key]
+		if !ok {
+			start, end := MonthBounds(now, time.UTC)
+			mtd = newMonthToDate(nil, start, end, now, 0)
+		}
+		status := &BudgetStatus{
+			Budget:               b,
+			EvaluatedAt:          now.UTC().Format(time.RFC3339),
+			MonthToDate:          mtd,
+			Utilization:          mtd.Cost / b.MonthlyAmount,
+			ProjectedUtilization: mtd.ProjectedCost / b.MonthlyAmount,
+		}
+
+		alerted := make(map[string]float64)
+		if b.AlertedMonth == month {
+			for alertType, t := range b.Alerted {
+				alerted[alertType] = t
+			}
+		}
+		thresholds := append([]float64(nil), b.Thresholds...)
+		sort.Float64s(thresholds)
+		var budgetAlerts []*BudgetAlert
+		for _, t := range thresholds {
+			for _, alertType := range []string{BudgetAlertActual, BudgetAlertForecast} {
+				utilization := status.Utilization
+				if alertType == BudgetAlertForecast {
+					utilization = status.ProjectedUtilization
+				}
+				if utilization < t || t <= alerted[alertType] {
+					continue
+				}
+				alerted[alertType] = t
+				urls := append(append([]string{}, webhookURLs...), b.WebhookURLs...)
+				budgetAlerts = append(budgetAlerts, newBudgetAlert(alertType, b, t, status, month, urls))
+			}
+		}
+		if len(budgetAlerts) > 0 {
+			// Budgets are shared with the statuses already listed, so alert state is updated on a copy.
+			updated := *b
+			updated.AlertedMonth = month
+			updated.Alerted = alerted
+			b = &updated
+			status.Budget = b
+			alerts = append(alerts, budgetAlerts...)
+		}
+		budgets = append(budgets, b)
+		s.statuses[budgetID(b.Aggregator, b.Key)] = status
+	}
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+	s.budgets = budgets
+	return alerts, s.save(budgets)
+}
+
+// EvaluateAggregations evaluates the budgets of the aggregator, as Evaluate does, against aggregations built from
+// hourly cost data since the start of the UTC month.
+func (s *BudgetStore) EvaluateAggregations(aggregator string, aggregations map[string]*Aggregation, webhookURLs []string, now time.Time) ([]*BudgetAlert, error) {
+	costs := AggregationsMonthToDate(aggregations, now, time.UTC, BudgetRunRateWindow)
+	return s.Evaluate(aggregator, costs, webhookURLs, now)
+}
+
+func newBudgetAlert(alertType string, b *Budget, threshold float64, status *BudgetStatus, month string, webhookURLs []string) *BudgetAlert {
+	alert := &BudgetAlert{
+		Type:                 alertType,
+		Aggregator:           b.Aggregator,
+		Key:                  b.Key,
+		Month:                month,
+		MonthlyAmount:        b.MonthlyAmount,
+		Threshold:            threshold,
+		MonthToDateCost:      status.MonthToDate.Cost,
+		ProjectedCost:        status.MonthToDate.ProjectedCost,
+		Utilization:          status.Utilization,
+		ProjectedUtilization: status.ProjectedUtilization,
+		Timestamp:            status.EvaluatedAt,
+		webhookURLs:          webhookURLs,
+	}
+	if alertType == BudgetAlertForecast {
+		alert.Message = fmt.Sprintf("%s %s is projected to spend %.2f in %s, %.0f%% of its budget of %.2f", b.Aggregator, b.Key, alert.ProjectedCost, month, status.ProjectedUtilization*100, b.MonthlyAmount)
+	} else {
+		alert.Message = fmt.Sprintf("%s %s has spent %.2f in %s, %.0f%% of its budget of %.2f", b.Aggregator, b.Key, alert.MonthToDateCost, month, status.Utilization*100, b.MonthlyAmount)
+	}
+	return alert
+}
+
+// WebhookURLs returns the URLs the alert is to be posted to.
+func (alert *BudgetAlert) WebhookURLs() []string {
+	return alert.webhookURLs
+}
+
+// PostBudgetAlert posts the alert as JSON to the webhook URL.
+func PostBudgetAlert(url string, alert *BudgetAlert) error {
+	b, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := budgetWebhookClient.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Budget webhook %s returned status %d", url, resp.StatusCode)
+	}
+	return nil
+}
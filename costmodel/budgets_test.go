@@ -0,0 +1,201 @@
+package costmodel
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBudgetStore(t *testing.T, budgets ...*Budget) *BudgetStore {
+	dir, err := ioutil.TempDir("", "budgets")
+	if err != nil {
+		t.Fatalf("Error creating a temporary directory: %s", err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	s, err := NewBudgetStore(filepath.Join(dir, "budgets.json"))
+	if err != nil {
+		t.Fatalf("Error creating the budget store: %s", err.Error())
+	}
+	for _, b := range budgets {
+		if err := s.Set(b); err != nil {
+			t.Fatalf("Error setting budget %s: %s", b.Key, err.Error())
+		}
+	}
+	return s
+}
+
+// hourlyCosts returns one vector per hour from an hour after start to now, costing cost(h) at hour h.
+func hourlyCosts(start time.Time, now time.Time, cost func(h int) float64) []*Vector {
+	var vectors []*Vector
+	for h := 1; !start.Add(time.Duration(h) * time.Hour).After(now); h++ {
+		vectors = append(vectors, &Vector{
+			Timestamp: float64(start.Add(time.Duration(h) * time.Hour).Unix()),
+			Value:     cost(h),
+		})
+	}
+	return vectors
+}
+
+func TestBudgetEvaluateAggregations(t *testing.T) {
+	monthStart := time.Date(2019, 9, 1, 0, 0, 0, 0, time.UTC)
+	now := monthStart.Add(10 * 24 * time.Hour) // 240 of the 720 hours of September
+	store := newTestBudgetStore(t,
+		&Budget{Aggregator: "namespace", Key: "flat", MonthlyAmount: 600},
+		&Budget{Aggregator: "namespace", Key: "rising", MonthlyAmount: 1000, Thresholds: []float64{0.25, 1.2}},
+		&Budget{Aggregator: "namespace", Key: "idle", MonthlyAmount: 100},
+		&Budget{Aggregator: "label:team", Key: "flat", MonthlyAmount: 1},
+	)
+	aggregations := map[string]*Aggregation{
+		"flat": {
+			CPUCostVector: hourlyCosts(monthStart, now, func(h int) float64 { return 0.5 }),
+			RAMCostVector: hourlyCosts(monthStart, now, func(h int) float64 { return 0.5 }),
+		},
+		// Costs double over the last day, which the run-rate projects over the rest of the month.
+		"rising": {
+			CPUCostVector: hourlyCosts(monthStart, now, func(h int) float64 {
+				if h > 216 {
+					return 2
+				}
+				return 1
+			}),
+		},
+	}
+
+	alerts, err := store.EvaluateAggregations("namespace", aggregations, []string{"http://example.com/hook"}, now)
+	if err != nil {
+		t.Fatalf("Error evaluating budgets: %s", err.Error())
+	}
+
+	// The run-rate window holds the 24 hourly steps covering the day before now.
+	risingCost := 216.0 + 24*2
+	risingRunRate := 2.0
+	cases := []struct {
+		key         string
+		cost        float64
+		projected   float64
+		utilization float64
+		projectedU  float64
+	}{
+		{"flat", 240, 720, 0.4, 1.2},
+		{"rising", risingCost, risingCost + risingRunRate*480, risingCost / 1000, (risingCost + risingRunRate*480) / 1000},
+		{"idle", 0, 0, 0, 0},
+	}
+	statuses := make(map[string]*BudgetStatus)
+	for _, status := range store.List() {
+		if status.Aggregator == "namespace" {
+			statuses[status.Key] = status
+		} else if status.MonthToDate != nil {
+			t.Errorf("Budget of %s %s was evaluated with the namespace budgets", status.Aggregator, status.Key)
+		}
+	}
+	for _, c := range cases {
+		status, ok := statuses[c.key]
+		if !ok || status.MonthToDate == nil {
+			t.Errorf("%s: budget was not evaluated", c.key)
+			continue
+		}
+		if !approxEqual(status.MonthToDate.Cost, c.cost) {
+			t.Errorf("%s: month-to-date cost is %f, expected %f", c.key, status.MonthToDate.Cost, c.cost)
+		}
+		if !approxEqual(status.MonthToDate.ProjectedCost, c.projected) {
+			t.Errorf("%s: projected cost is %f, expected %f", c.key, status.MonthToDate.ProjectedCost, c.projected)
+		}
+		if !approxEqual(status.Utilization, c.utilization) {
+			t.Errorf("%s: utilization is %f, expected %f", c.key, status.Utilization, c.utilization)
+		}
+		if !approxEqual(status.ProjectedUtilization, c.projectedU) {
+			t.Errorf("%s: projected utilization is %f, expected %f", c.key, status.ProjectedUtilization, c.projectedU)
+		}
+	}
+
+	expected := map[string]bool{
+		"flat,forecast,0.8":    true,
+		"flat,forecast,1":      true,
+		"rising,actual,0.25":   true,
+		"rising,forecast,0.25": true,
+		"rising,forecast,1.2":  true,
+	}
+	if len(alerts) != len(expected) {
+		t.Errorf("Got %d alerts, expected %d", len(alerts), len(expected))
+	}
+	for _, alert := range alerts {
+		id := alert.Key + "," + alert.Type + "," + fmt.Sprintf("%g", alert.Threshold)
+		if !expected[id] {
+			t.Errorf("Unexpected alert %s: %s", id, alert.Message)
+		}
+		if len(alert.WebhookURLs()) != 1 {
+			t.Errorf("Alert %s is posted to %v, expected the configured webhook", id, alert.WebhookURLs())
+		}
+	}
+
+	// Thresholds alert once a month, even after a restart.
+	if alerts, _ := store.EvaluateAggregations("namespace", aggregations, nil, now.Add(time.Hour)); len(alerts) != 0 {
+		t.Errorf("Got %d alerts on the second evaluation, expected none", len(alerts))
+	}
+	restarted, err := NewBudgetStore(store.path)
+	if err != nil {
+		t.Fatalf("Error reloading the budgets: %s", err.Error())
+	}
+	if alerts, _ := restarted.EvaluateAggregations("namespace", aggregations, nil, now.Add(time.Hour)); len(alerts) != 0 {
+		t.Errorf("Got %d alerts after a restart, expected none", len(alerts))
+	}
+}
+
+func TestBudgetEvaluateAlertedThresholds(t *testing.T) {
+	store := newTestBudgetStore(t, &Budget{Aggregator: "namespace", Key: "ns1", MonthlyAmount: 100, Thresholds: []float64{1, 0.5, 0.8}})
+	now := time.Date(2019, 9, 10, 0, 0, 0, 0, time.UTC)
+	evaluate := func(cost float64, now time.Time) []string {
+		start, end := MonthBounds(now, time.UTC)
+		mtd := newMonthToDate(nil, start, end, now, 0)
+		mtd.Cost = cost
+		alerts, err := store.Evaluate("namespace", map[string]*MonthToDate{"ns1": mtd}, nil, now)
+		if err != nil {
+			t.Fatalf("Error evaluating budgets: %s", err.Error())
+		}
+		var ids []string
+		for _, alert := range alerts {
+			ids = append(ids, fmt.Sprintf("%s,%g", alert.Type, alert.Threshold))
+		}
+		return ids
+	}
+	cases := []struct {
+		name     string
+		cost     float64
+		now      time.Time
+		expected []string
+	}{
+		{"below every threshold", 40, now, nil},
+		{"crossing two thresholds at once", 85, now, []string{"actual,0.5", "actual,0.8"}},
+		{"already alerted", 90, now, nil},
+		{"crossing the last threshold", 120, now.Add(time.Hour), []string{"actual,1"}},
+		{"next month", 60, now.AddDate(0, 1, 0), []string{"actual,0.5"}},
+	}
+	for _, c := range cases {
+		ids := evaluate(c.cost, c.now)
+		if fmt.Sprint(ids) != fmt.Sprint(c.expected) {
+			t.Errorf("%s: got alerts %v, expected %v", c.name, ids, c.expected)
+		}
+	}
+
+	// The highest threshold alerted is persisted with the budget.
+	restarted, err := NewBudgetStore(store.path)
+	if err != nil {
+		t.Fatalf("Error reloading the budgets: %s", err.Error())
+	}
+	b := restarted.List()[0].Budget
+	if b.AlertedMonth != "2019-10" || b.Alerted[BudgetAlertActual] != 0.5 {
+		t.Errorf("Persisted alerts are %v in %s, expected actual at 0.5 in 2019-10", b.Alerted, b.AlertedMonth)
+	}
+
+	// Replacing a budget resets its alerts.
+	b.Alerted = map[string]float64{BudgetAlertActual: 2}
+	if err := store.Set(b); err != nil {
+		t.Fatalf("Error setting the budget: %s", err.Error())
+	}
+	if ids := evaluate(60, now.AddDate(0, 1, 0)); fmt.Sprint(ids) != fmt.Sprint([]string{"actual,0.5"}) {
+		t.Errorf("Got alerts %v after replacing the budget, expected actual,0.5", ids)
+	}
+}
@@ -0,0 +1,109 @@
+package costmodel
+
+import (
+	"strings"
+
+	costAnalyzerCloud "github.com/kubecost/cost-model/cloud"
+	"k8s.io/klog"
+)
+
+const (
+	costCenterSourceLabel          = "label"
+	costCenterSourceAnnotation     = "annotation"
+	costCenterSourceNamespaceLabel = "namespaceLabel"
+)
+
+// costCenterSource is a single pod label, pod annotation or namespace label a cost center field can be read from.
+type costCenterSource struct {
+	Kind string
+	Name string
+}
+
+// CostCenterMapping holds, for each cost center field of CostData, the ordered list of sources it is resolved from.
+// The first source with a non-empty value wins.
+type CostCenterMapping struct {
+	Owner       []costCenterSource
+	Department  []costCenterSource
+	Product     []costCenterSource
+	Environment []costCenterSource
+}
+
+// NewCostCenterMapping reads the cost center sources from the pricing configuration. Each field is a comma separated
+// list of sources such as "team,annotation:owner,namespaceLabel:cost-center", where unprefixed names are pod labels.
+func NewCostCenterMapping(cfg *costAnalyzerCloud.CustomPricing) *CostCenterMapping {
+	return &CostCenterMapping{
+		Owner:       parseCostCenterSources(cfg.OwnerLabel),
+		Department:  parseCostCenterSources(cfg.DepartmentLabel),
+		Product:     parseCostCenterSources(cfg.ProductLabel),
+		Environment: parseCostCenterSources(cfg.EnvironmentLabel),
+	}
+}
+
+func parseCostCenterSources(sources string) []costCenterSource {
+	var result []costCenterSource
+	for _, s := range strings.Split(sources, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		source := costCenterSource{
+			Kind: costCenterSourceLabel,
+			Name: s,
+		}
+		if kv := strings.SplitN(s, ":", 2); len(kv) == 2 {
+			switch kv[0] {
+			case costCenterSourceLabel, costCenterSourceAnnotation, costCenterSourceNamespaceLabel:
+				source.Kind = kv[0]
+				source.Name = kv[1]
+			default:
+				klog.V(3).Infof("Unknown cost center source %s, treating it as a pod label", s)
+			}
+		}
+		result = append(result, source)
+	}
+	return result
+}
+
+// annotationNames returns the annotations cost center fields are read from, so they are captured on CostData
+// whether or not they are in the annotations allowlist.
+func (m *CostCenterMapping) annotationNames() []string {
+	var names []string
+	for _, sources := range [][]costCenterSource{m.Owner, m.Department, m.Product, m.Environment} {
+		for _, source := range sources {
+			if source.Kind == costCenterSourceAnnotation {
+				names = append(names, source.Name)
+			}
+		}
+	}
+	return names
+}
+
+// resolveCostCenter returns the value of the first source set on the container, or "" if none is. Annotations are
+// those captured on CostData, where a pod annotation overrides the namespace annotation of the same name.
+func resolveCostCenter(sources []costCenterSource, costDatum *CostData) string {
+	for _, source := range sources {
+		var value string
+		switch source.Kind {
+		case costCenterSourceLabel:
+			value = costDatum.Labels[source.Name]
+		case costCenterSourceAnnotation:
+			value = costDatum.Annotations[source.Name]
+		case costCenterSourceNamespaceLabel:
+			value = costDatum.NamespaceLabels[source.Name]
+		}
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// applyCostCenters sets the owner, department, product and environment of every container.
+func applyCostCenters(costData map[string]*CostData, mapping *CostCenterMapping) {
+	for _, costDatum := range costData {
+		costDatum.Owner = resolveCostCenter(mapping.Owner, costDatum)
+		costDatum.Department = resolveCostCenter(mapping.Department, costDatum)
+		costDatum.Product = resolveCostCenter(mapping.Product, costDatum)
+		costDatum.Environment = resolveCostCenter(mapping.Environment, costDatum)
+	}
+}
@@ -0,0 +1,49 @@
+package costmodel
+
+import (
+	"testing"
+
+	costAnalyzerCloud "github.com/kubecost/cost-model/cloud"
+)
+
+func TestApplyCostCenters(t *testing.T) {
+	mapping := NewCostCenterMapping(&costAnalyzerCloud.CustomPricing{
+		OwnerLabel:      "owner,annotation:example.com/owner",
+		DepartmentLabel: "namespaceLabel:cost-center",
+		ProductLabel:    "annotation:example.com/product",
+	})
+	names := mapping.annotationNames()
+	if len(names) != 2 || names[0] != "example.com/owner" || names[1] != "example.com/product" {
+		t.Errorf("Annotation names are %v, expected the owner and product annotations", names)
+	}
+
+	costData := map[string]*CostData{
+		"labelled": {
+			Labels:          map[string]string{"owner": "alice"},
+			Annotations:     map[string]string{"example.com/owner": "bob"},
+			NamespaceLabels: map[string]string{"cost-center": "cc-1"},
+		},
+		"annotated": {
+			Annotations: map[string]string{"example.com/owner": "bob", "example.com/product": "shop"},
+		},
+		"none": {},
+	}
+	applyCostCenters(costData, mapping)
+
+	cases := []struct {
+		key        string
+		owner      string
+		department string
+		product    string
+	}{
+		{"labelled", "alice", "cc-1", ""},
+		{"annotated", "bob", "", "shop"},
+		{"none", "", "", ""},
+	}
+	for _, c := range cases {
+		cd := costData[c.key]
+		if cd.Owner != c.owner || cd.Department != c.department || cd.Product != c.product {
+			t.Errorf("%s: got owner %q, department %q and product %q, expected %q, %q and %q", c.key, cd.Owner, cd.Department, cd.Product, c.owner, c.department, c.product)
+		}
+	}
+}
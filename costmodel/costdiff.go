@@ -0,0 +1,68 @@
+package costmodel
+
+import (
+	"sort"
+)
+
+// CostDiff is the change in total cost of one aggregation key between two windows.
+type CostDiff struct {
+	Key          string  `json:"key"`
+	CurrentCost  float64 `json:"currentCost"`
+	PreviousCost float64 `json:"previousCost"`
+	Change       float64 `json:"change"`
+	// PercentChange is relative to the previous cost, and null when there was none, e.g. for new keys.
+	PercentChange *float64 `json:"percentChange"`
+	New           bool     `json:"new"`
+	Disappeared   bool     `json:"disappeared"`
+}
+
+// CostComparison is the per key difference between the aggregated costs of two windows.
+type CostComparison struct {
+	Start         string      `json:"start"`
+	End           string      `json:"end"`
+	PreviousStart string      `json:"previousStart"`
+	PreviousEnd   string      `json:"previousEnd"`
+	Diffs         []*CostDiff `json:"diffs"`
+}
+
+// DiffAggregations compares the total cost of each key in two aggregations, sorted by largest increase first. Keys
+// found in only one of them are flagged as new or disappeared.
+func DiffAggregations(current map[string]*Aggregation, previous map[string]*Aggregation) []*CostDiff {
+	diffs := make([]*CostDiff, 0, len(current))
+	for key, agg := range current {
+		diff := &CostDiff{
+			Key:         key,
+			CurrentCost: agg.TotalCost,
+		}
+		if prev, ok := previous[key]; ok {
+			diff.PreviousCost = prev.TotalCost
+		} else {
+			diff.New = true
+		}
+		diffs = append(diffs, diff)
+	}
+	for key, prev := range previous {
+		if _, ok := current[key]; !ok {
+			diffs = append(diffs, &CostDiff{
+				Key:          key,
+				PreviousCost: prev.TotalCost,
+				Disappeared:  true,
+			})
+		}
+	}
+
+	for _, diff := range diffs {
+		diff.Change = diff.CurrentCost - diff.PreviousCost
+		if diff.PreviousCost != 0 {
+			percent := diff.Change / diff.PreviousCost * 100
+			diff.PercentChange = &percent
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].Change == diffs[j].Change {
+			return diffs[i].Key < diffs[j].Key
+		}
+		return diffs[i].Change > diffs[j].Change
+	})
+	return diffs
+}
@@ -0,0 +1,59 @@
+package costmodel
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffAggregations(t *testing.T) {
+	current := map[string]*Aggregation{
+		"grown":   {TotalCost: 30},
+		"shrunk":  {TotalCost: 5},
+		"same":    {TotalCost: 10},
+		"new":     {TotalCost: 8},
+		"was-0":   {TotalCost: 2},
+		"still-0": {TotalCost: 0},
+	}
+	previous := map[string]*Aggregation{
+		"grown":   {TotalCost: 20},
+		"shrunk":  {TotalCost: 10},
+		"same":    {TotalCost: 10},
+		"gone":    {TotalCost: 4},
+		"was-0":   {TotalCost: 0},
+		"still-0": {TotalCost: 0},
+	}
+	percent := func(p float64) *float64 { return &p }
+	expected := []*CostDiff{
+		{Key: "grown", CurrentCost: 30, PreviousCost: 20, Change: 10, PercentChange: percent(50)},
+		{Key: "new", CurrentCost: 8, Change: 8, New: true},
+		{Key: "was-0", CurrentCost: 2, Change: 2},
+		// Keys that did not change are sorted by name.
+		{Key: "same", CurrentCost: 10, PreviousCost: 10, PercentChange: percent(0)},
+		{Key: "still-0"},
+		{Key: "gone", PreviousCost: 4, Change: -4, PercentChange: percent(-100), Disappeared: true},
+		{Key: "shrunk", CurrentCost: 5, PreviousCost: 10, Change: -5, PercentChange: percent(-50)},
+	}
+
+	diffs := DiffAggregations(current, previous)
+	if len(diffs) != len(expected) {
+		t.Fatalf("Got %d diffs, expected %d", len(diffs), len(expected))
+	}
+	for i, e := range expected {
+		d := diffs[i]
+		if d.Key != e.Key {
+			t.Errorf("Diff %d is of %s, expected %s", i, d.Key, e.Key)
+			continue
+		}
+		if d.CurrentCost != e.CurrentCost || d.PreviousCost != e.PreviousCost || d.Change != e.Change || d.New != e.New || d.Disappeared != e.Disappeared {
+			t.Errorf("Diff of %s is %+v, expected %+v", d.Key, *d, *e)
+		}
+		if (d.PercentChange == nil) != (e.PercentChange == nil) || (d.PercentChange != nil && !approxEqual(*d.PercentChange, *e.PercentChange)) {
+			t.Errorf("Percent change of %s is %v, expected %v", d.Key, d.PercentChange, e.PercentChange)
+		}
+	}
+
+	// Diffs without a percentage still encode.
+	if _, err := json.Marshal(diffs); err != nil {
+		t.Errorf("Unable to encode diffs: %s", err.Error())
+	}
+}
@@ -44,6 +44,9 @@ const (
 
 type CostModel struct {
 	Cache ClusterCache
+	// AllocationStrategy is how container allocation is computed from requests and usage, set from the
+	// allocationStrategy and allocationWeight config. Defaults to AllocationMax.
+	AllocationStrategy *AllocationStrategy
 
 	stop chan struct{}
 }
@@ -60,28 +63,38 @@ func NewCostModel(client kubernetes.Interface) *CostModel {
 }
 
 type CostData struct {
-	Name            string                       `json:"name,omitempty"`
-	PodName         string                       `json:"podName,omitempty"`
-	NodeName        string                       `json:"nodeName,omitempty"`
-	NodeData        *costAnalyzerCloud.Node      `json:"node,omitempty"`
-	Namespace       string                       `json:"namespace,omitempty"`
-	Deployments     []string                     `json:"deployments,omitempty"`
-	Services        []string                     `json:"services,omitempty"`
-	Daemonsets      []string                     `json:"daemonsets,omitempty"`
-	Statefulsets    []string                     `json:"statefulsets,omitempty"`
-	Jobs            []string                     `json:"jobs,omitempty"`
-	RAMReq          []*Vector                    `json:"ramreq,omitempty"`
-	RAMUsed         []*Vector                    `json:"ramused,omitempty"`
-	CPUReq          []*Vector                    `json:"cpureq,omitempty"`
-	CPUUsed         []*Vector                    `json:"cpuused,omitempty"`
-	RAMAllocation   []*Vector                    `json:"ramallocated,omitempty"`
-	CPUAllocation   []*Vector                    `json:"cpuallocated,omitempty"`
-	GPUReq          []*Vector                    `json:"gpureq,omitempty"`
-	PVCData         []*PersistentVolumeClaimData `json:"pvcData,omitempty"`
-	NetworkData     []*Vector                    `json:"network,omitempty"`
-	Labels          map[string]string            `json:"labels,omitempty"`
-	NamespaceLabels map[string]string            `json:"namespaceLabels,omitempty"`
-	ClusterID       string                       `json:"clusterId"`
+	Name                 string                       `json:"name,omitempty"`
+	PodName              string                       `json:"podName,omitempty"`
+	NodeName             string                       `json:"nodeName,omitempty"`
+	NodeData             *costAnalyzerCloud.Node      `json:"node,omitempty"`
+	Namespace            string                       `json:"namespace,omitempty"`
+	Deployments          []string                     `json:"deployments,omitempty"`
+	Services             []string                     `json:"services,omitempty"`
+	Daemonsets           []string                     `json:"daemonsets,omitempty"`
+	Statefulsets         []string                     `json:"statefulsets,omitempty"`
+	Jobs                 []string                     `json:"jobs,omitempty"`
+	CronJobs             []string                     `json:"cronjobs,omitempty"`
+	RAMReq               []*Vector                    `json:"ramreq,omitempty"`
+	RAMUsed              []*Vector                    `json:"ramused,omitempty"`
+	CPUReq               []*Vector                    `json:"cpureq,omitempty"`
+	CPUUsed              []*Vector                    `json:"cpuused,omitempty"`
+	RAMAllocation        []*Vector                    `json:"ramallocated,omitempty"`
+	CPUAllocation        []*Vector                    `json:"cpuallocated,omitempty"`
+	GPUReq               []*Vector                    `json:"gpureq,omitempty"`
+	PVCData              []*PersistentVolumeClaimData `json:"pvcData,omitempty"`
+	NetworkData          []*Vector                    `json:"network,omitempty"`
+	NetworkTiers         *NetworkCostData             `json:"networkTiers,omitempty"`
+	Labels               map[string]string            `json:"labels,omitempty"`
+	NamespaceLabels      map[string]string            `json:"namespaceLabels,omitempty"`
+	Annotations          map[string]string            `json:"annotations,omitempty"`
+	NamespaceAnnotations map[string]string            `json:"namespaceAnnotations,omitempty"`
+	Owner                string                       `json:"owner,omitempty"`
+	Department           string                       `json:"department,omitempty"`
+	Product              string                       `json:"product,omitempty"`
+	Environment          string                       `json:"environment,omitempty"`
+	QoSClass             string                       `json:"qosClass,omitempty"`
+	PriorityClass        string                       `json:"priorityClass,omitempty"`
+	ClusterID            string                       `json:"clusterId"`
 }
 
 type Vector struct {
@@ -266,6 +279,12 @@ func (cm *CostModel) ComputeCostData(cli prometheusClient.Client, clientset kube
 	normalization := fmt.Sprintf(normalizationStr, window, offset)
 
 	clustID := os.Getenv(CLUSTER_ID)
+	cfg, err := cloud.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	costCenters := NewCostCenterMapping(cfg)
+	annotationsAllowlist := append(newAnnotationsAllowlist(cfg.AnnotationsAllowlist), costCenters.annotationNames()...)
 
 	var wg sync.WaitGroup
 	wg.Add(11)
@@ -325,6 +344,8 @@ func (cm *CostModel) ComputeCostData(cli prometheusClient.Client, clientset kube
 	podDeploymentsMapping := make(map[string]map[string][]string)
 	podServicesMapping := make(map[string]map[string][]string)
 	namespaceLabelsMapping := make(map[string]map[string]string)
+	namespaceAnnotationsMapping := make(map[string]map[string]string)
+	jobCronJobsMapping := make(map[string]map[string]string)
 	podlist := cm.Cache.GetAllPods()
 	var k8sErr error
 	go func() {
@@ -343,6 +364,14 @@ func (cm *CostModel) ComputeCostData(cli prometheusClient.Client, clientset kube
 		if k8sErr != nil {
 			return
 		}
+		namespaceAnnotationsMapping, k8sErr = getNamespaceAnnotations(cm.Cache, annotationsAllowlist)
+		if k8sErr != nil {
+			return
+		}
+		jobCronJobsMapping, k8sErr = getJobCronJobs(cm.Cache)
+		if k8sErr != nil {
+			return
+		}
 
 	}()
 
@@ -458,6 +487,9 @@ func (cm *CostModel) ComputeCostData(cli prometheusClient.Client, clientset kube
 				}
 			}
 
+			nsAnnotations := namespaceAnnotationsMapping[ns]
+			podAnnotations := withNamespaceAnnotations(annotationsAllowlist.filter(pod.GetObjectMeta().GetAnnotations()), nsAnnotations)
+
 			nodeName := pod.Spec.NodeName
 			var nodeData *costAnalyzerCloud.Node
 			if _, ok := nodes[nodeName]; ok {
@@ -484,12 +516,14 @@ func (cm *CostModel) ComputeCostData(cli prometheusClient.Client, clientset kube
 			}
 
 			var podNetCosts []*Vector
+			var podNetTiers *NetworkCostData
 			if usage, ok := networkUsageMap[ns+","+podName]; ok {
-				netCosts, err := GetNetworkCost(usage, cloud)
+				netTiers, err := GetNetworkTierCosts(usage, cloud)
 				if err != nil {
 					klog.V(3).Infof("Error pulling network costs: %s", err.Error())
 				} else {
-					podNetCosts = netCosts
+					podNetCosts = netTiers.Total()
+					podNetTiers = netTiers
 				}
 			}
 
@@ -536,35 +570,43 @@ func (cm *CostModel) ComputeCostData(cli prometheusClient.Client, clientset kube
 
 				var pvReq []*PersistentVolumeClaimData
 				var netReq []*Vector
+				var netTiersReq *NetworkCostData
 				if i == 0 { // avoid duplicating by just assigning all claims to the first container.
 					pvReq = podPVs
 					netReq = podNetCosts
+					netTiersReq = podNetTiers
 				}
 
 				costs := &CostData{
-					Name:            containerName,
-					PodName:         podName,
-					NodeName:        nodeName,
-					Namespace:       ns,
-					Deployments:     podDeployments,
-					Services:        podServices,
-					Daemonsets:      getDaemonsetsOfPod(pod),
-					Jobs:            getJobsOfPod(pod),
-					Statefulsets:    getStatefulSetsOfPod(pod),
-					NodeData:        nodeData,
-					RAMReq:          RAMReqV,
-					RAMUsed:         RAMUsedV,
-					CPUReq:          CPUReqV,
-					CPUUsed:         CPUUsedV,
-					GPUReq:          GPUReqV,
-					PVCData:         pvReq,
-					NetworkData:     netReq,
-					Labels:          podLabels,
-					NamespaceLabels: nsLabels,
-					ClusterID:       clustID,
+					Name:                 containerName,
+					PodName:              podName,
+					NodeName:             nodeName,
+					Namespace:            ns,
+					Deployments:          podDeployments,
+					Services:             podServices,
+					Daemonsets:           getDaemonsetsOfPod(pod),
+					Jobs:                 getJobsOfPod(pod),
+					CronJobs:             getCronJobsOfPod(pod, jobCronJobsMapping),
+					Statefulsets:         getStatefulSetsOfPod(pod),
+					QoSClass:             string(pod.Status.QOSClass),
+					PriorityClass:        pod.Spec.PriorityClassName,
+					NodeData:             nodeData,
+					RAMReq:               RAMReqV,
+					RAMUsed:              RAMUsedV,
+					CPUReq:               CPUReqV,
+					CPUUsed:              CPUUsedV,
+					GPUReq:               GPUReqV,
+					PVCData:              pvReq,
+					NetworkData:          netReq,
+					NetworkTiers:         netTiersReq,
+					Labels:               podLabels,
+					NamespaceLabels:      nsLabels,
+					Annotations:          podAnnotations,
+					NamespaceAnnotations: nsAnnotations,
+					ClusterID:            clustID,
 				}
-				costs.CPUAllocation = getContainerAllocation(costs.CPUReq, costs.CPUUsed)
-				costs.RAMAllocation = getContainerAllocation(costs.RAMReq, costs.RAMUsed)
+				costs.CPUAllocation = getContainerAllocation(costs.CPUReq, costs.CPUUsed, cm.AllocationStrategy)
+				costs.RAMAllocation = getContainerAllocation(costs.RAMReq, costs.RAMUsed, cm.AllocationStrategy)
 				if filterNamespace == "" {
 					containerNameCost[newKey] = costs
 				} else if costs.Namespace == filterNamespace {
@@ -619,21 +661,23 @@ func (cm *CostModel) ComputeCostData(cli prometheusClient.Client, clientset kube
 				klog.V(3).Infof("Missing data for namespace %s", c.Namespace)
 			}
 			costs := &CostData{
-				Name:            c.ContainerName,
-				PodName:         c.PodName,
-				NodeName:        c.NodeName,
-				NodeData:        node,
-				Namespace:       c.Namespace,
-				RAMReq:          RAMReqV,
-				RAMUsed:         RAMUsedV,
-				CPUReq:          CPUReqV,
-				CPUUsed:         CPUUsedV,
-				GPUReq:          GPUReqV,
-				NamespaceLabels: namespacelabels,
-				ClusterID:       clustID,
+				Name:                 c.ContainerName,
+				PodName:              c.PodName,
+				NodeName:             c.NodeName,
+				NodeData:             node,
+				Namespace:            c.Namespace,
+				RAMReq:               RAMReqV,
+				RAMUsed:              RAMUsedV,
+				CPUReq:               CPUReqV,
+				CPUUsed:              CPUUsedV,
+				GPUReq:               GPUReqV,
+				NamespaceLabels:      namespacelabels,
+				Annotations:          withNamespaceAnnotations(nil, namespaceAnnotationsMapping[c.Namespace]),
+				NamespaceAnnotations: namespaceAnnotationsMapping[c.Namespace],
+				ClusterID:            clustID,
 			}
-			costs.CPUAllocation = getContainerAllocation(costs.CPUReq, costs.CPUUsed)
-			costs.RAMAllocation = getContainerAllocation(costs.RAMReq, costs.RAMUsed)
+			costs.CPUAllocation = getContainerAllocation(costs.CPUReq, costs.CPUUsed, cm.AllocationStrategy)
+			costs.RAMAllocation = getContainerAllocation(costs.RAMReq, costs.RAMUsed, cm.AllocationStrategy)
 			if filterNamespace == "" {
 				containerNameCost[key] = costs
 				missingContainers[key] = costs
@@ -652,6 +696,7 @@ func (cm *CostModel) ComputeCostData(cli prometheusClient.Client, clientset kube
 	if err != nil {
 		return nil, err
 	}
+	applyCostCenters(containerNameCost, costCenters)
 	return containerNameCost, err
 }
 
@@ -792,8 +837,9 @@ func findDeletedNodeInfo(cli prometheusClient.Client, missingNodes map[string]*c
 	return nil
 }
 
-func getContainerAllocation(req []*Vector, used []*Vector) []*Vector {
-	if req == nil || len(req) == 0 {
+func getContainerAllocation(req []*Vector, used []*Vector, strategy *AllocationStrategy) []*Vector {
+	// With max allocation, a container missing requests or usage is allocated the other one as is.
+	if strategy.isMax() && (req == nil || len(req) == 0) {
 		for _, usedV := range used {
 			if usedV.Timestamp == 0 {
 				continue
@@ -802,7 +848,7 @@ func getContainerAllocation(req []*Vector, used []*Vector) []*Vector {
 		}
 		return used
 	}
-	if used == nil || len(used) == 0 {
+	if strategy.isMax() && (used == nil || len(used) == 0) {
 		for _, reqV := range req {
 			if reqV.Timestamp == 0 {
 				continue
@@ -842,12 +888,8 @@ func getContainerAllocation(req []*Vector, used []*Vector) []*Vector {
 		allocationVector := &Vector{
 			Timestamp: t,
 		}
-		if okR && okU {
-			allocationVector.Value = math.Max(rv, uv)
-		} else if okR {
-			allocationVector.Value = rv
-		} else if okU {
-			allocationVector.Value = uv
+		if okR || okU {
+			allocationVector.Value = strategy.allocate(rv, uv)
 		}
 		allocation = append(allocation, allocationVector)
 	}
@@ -1043,11 +1085,30 @@ func getNodeCost(cache ClusterCache, cloud costAnalyzerCloud.Provider) (map[stri
 			}
 		}
 
+		newCnode.Tenant = getNodeTenant(n, cfg)
+
 		nodes[name] = &newCnode
 	}
 	return nodes, nil
 }
 
+// getNodeTenant returns the tenant a node is dedicated to, read from the value of the configured label or taint.
+func getNodeTenant(n *v1.Node, cfg *costAnalyzerCloud.CustomPricing) string {
+	if cfg.DedicatedNodeLabel != "" {
+		if tenant, ok := n.GetObjectMeta().GetLabels()[cfg.DedicatedNodeLabel]; ok && tenant != "" {
+			return tenant
+		}
+	}
+	if cfg.DedicatedNodeTaint != "" {
+		for _, taint := range n.Spec.Taints {
+			if taint.Key == cfg.DedicatedNodeTaint && taint.Value != "" {
+				return taint.Value
+			}
+		}
+	}
+	return ""
+}
+
 func getPodServices(cache ClusterCache, podList []*v1.Pod) (map[string]map[string][]string, error) {
 	servicesList := cache.GetAllServices()
 	podServicesMapping := make(map[string]map[string][]string)
@@ -1133,6 +1194,12 @@ func (cm *CostModel) ComputeCostDataRange(cli prometheusClient.Client, clientset
 		return nil, err
 	}
 	clustID := os.Getenv(CLUSTER_ID)
+	cfg, err := cloud.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	costCenters := NewCostCenterMapping(cfg)
+	annotationsAllowlist := append(newAnnotationsAllowlist(cfg.AnnotationsAllowlist), costCenters.annotationNames()...)
 	remoteEnabled := os.Getenv(remoteEnabled)
 	if remoteEnabled == "true" {
 		remoteLayout := "2006-01-02T15:04:05Z"
@@ -1200,6 +1267,8 @@ func (cm *CostModel) ComputeCostDataRange(cli prometheusClient.Client, clientset
 	podDeploymentsMapping := make(map[string]map[string][]string)
 	podServicesMapping := make(map[string]map[string][]string)
 	namespaceLabelsMapping := make(map[string]map[string]string)
+	namespaceAnnotationsMapping := make(map[string]map[string]string)
+	jobCronJobsMapping := make(map[string]map[string]string)
 	podlist := cm.Cache.GetAllPods()
 	var k8sErr error
 	go func() {
@@ -1217,6 +1286,14 @@ func (cm *CostModel) ComputeCostDataRange(cli prometheusClient.Client, clientset
 		if k8sErr != nil {
 			return
 		}
+		namespaceAnnotationsMapping, k8sErr = getNamespaceAnnotations(cm.Cache, annotationsAllowlist)
+		if k8sErr != nil {
+			return
+		}
+		jobCronJobsMapping, k8sErr = getJobCronJobs(cm.Cache)
+		if k8sErr != nil {
+			return
+		}
 
 		wg.Done()
 	}()
@@ -1348,12 +1425,14 @@ func (cm *CostModel) ComputeCostDataRange(cli prometheusClient.Client, clientset
 			}
 
 			var podNetCosts []*Vector
+			var podNetTiers *NetworkCostData
 			if usage, ok := networkUsageMap[ns+","+podName]; ok {
-				netCosts, err := GetNetworkCost(usage, cloud)
+				netTiers, err := GetNetworkTierCosts(usage, cloud)
 				if err != nil {
 					klog.V(3).Infof("Error pulling network costs: %s", err.Error())
 				} else {
-					podNetCosts = netCosts
+					podNetCosts = netTiers.Total()
+					podNetTiers = netTiers
 				}
 			}
 
@@ -1379,6 +1458,9 @@ func (cm *CostModel) ComputeCostDataRange(cli prometheusClient.Client, clientset
 				}
 			}
 
+			nsAnnotations := namespaceAnnotationsMapping[ns]
+			podAnnotations := withNamespaceAnnotations(annotationsAllowlist.filter(pod.GetObjectMeta().GetAnnotations()), nsAnnotations)
+
 			for i, container := range pod.Spec.Containers {
 				containerName := container.Name
 
@@ -1412,35 +1494,43 @@ func (cm *CostModel) ComputeCostDataRange(cli prometheusClient.Client, clientset
 
 				var pvReq []*PersistentVolumeClaimData
 				var netReq []*Vector
+				var netTiersReq *NetworkCostData
 				if i == 0 { // avoid duplicating by just assigning all claims to the first container.
 					pvReq = podPVs
 					netReq = podNetCosts
+					netTiersReq = podNetTiers
 				}
 
 				costs := &CostData{
-					Name:            containerName,
-					PodName:         podName,
-					NodeName:        nodeName,
-					Namespace:       ns,
-					Deployments:     podDeployments,
-					Services:        podServices,
-					Daemonsets:      getDaemonsetsOfPod(pod),
-					Jobs:            getJobsOfPod(pod),
-					Statefulsets:    getStatefulSetsOfPod(pod),
-					NodeData:        nodeData,
-					RAMReq:          RAMReqV,
-					RAMUsed:         RAMUsedV,
-					CPUReq:          CPUReqV,
-					CPUUsed:         CPUUsedV,
-					GPUReq:          GPUReqV,
-					PVCData:         pvReq,
-					Labels:          podLabels,
-					NetworkData:     netReq,
-					NamespaceLabels: nsLabels,
-					ClusterID:       clustID,
+					Name:                 containerName,
+					PodName:              podName,
+					NodeName:             nodeName,
+					Namespace:            ns,
+					Deployments:          podDeployments,
+					Services:             podServices,
+					Daemonsets:           getDaemonsetsOfPod(pod),
+					Jobs:                 getJobsOfPod(pod),
+					CronJobs:             getCronJobsOfPod(pod, jobCronJobsMapping),
+					Statefulsets:         getStatefulSetsOfPod(pod),
+					QoSClass:             string(pod.Status.QOSClass),
+					PriorityClass:        pod.Spec.PriorityClassName,
+					NodeData:             nodeData,
+					RAMReq:               RAMReqV,
+					RAMUsed:              RAMUsedV,
+					CPUReq:               CPUReqV,
+					CPUUsed:              CPUUsedV,
+					GPUReq:               GPUReqV,
+					PVCData:              pvReq,
+					Labels:               podLabels,
+					NetworkData:          netReq,
+					NetworkTiers:         netTiersReq,
+					NamespaceLabels:      nsLabels,
+					Annotations:          podAnnotations,
+					NamespaceAnnotations: nsAnnotations,
+					ClusterID:            clustID,
 				}
-				costs.CPUAllocation = getContainerAllocation(costs.CPUReq, costs.CPUUsed)
-				costs.RAMAllocation = getContainerAllocation(costs.RAMReq, costs.RAMUsed)
+				costs.CPUAllocation = getContainerAllocation(costs.CPUReq, costs.CPUUsed, cm.AllocationStrategy)
+				costs.RAMAllocation = getContainerAllocation(costs.RAMReq, costs.RAMUsed, cm.AllocationStrategy)
 				if filterNamespace == "" {
 					containerNameCost[newKey] = costs
 				} else if costs.Namespace == filterNamespace {
@@ -1493,21 +1583,23 @@ func (cm *CostModel) ComputeCostDataRange(cli prometheusClient.Client, clientset
 				klog.V(3).Infof("Missing data for namespace %s", c.Namespace)
 			}
 			costs := &CostData{
-				Name:            c.ContainerName,
-				PodName:         c.PodName,
-				NodeName:        c.NodeName,
-				NodeData:        node,
-				Namespace:       c.Namespace,
-				RAMReq:          RAMReqV,
-				RAMUsed:         RAMUsedV,
-				CPUReq:          CPUReqV,
-				CPUUsed:         CPUUsedV,
-				GPUReq:          GPUReqV,
-				NamespaceLabels: namespacelabels,
-				ClusterID:       clustID,
+				Name:                 c.ContainerName,
+				PodName:              c.PodName,
+				NodeName:             c.NodeName,
+				NodeData:             node,
+				Namespace:            c.Namespace,
+				RAMReq:               RAMReqV,
+				RAMUsed:              RAMUsedV,
+				CPUReq:               CPUReqV,
+				CPUUsed:              CPUUsedV,
+				GPUReq:               GPUReqV,
+				NamespaceLabels:      namespacelabels,
+				Annotations:          withNamespaceAnnotations(nil, namespaceAnnotationsMapping[c.Namespace]),
+				NamespaceAnnotations: namespaceAnnotationsMapping[c.Namespace],
+				ClusterID:            clustID,
 			}
-			costs.CPUAllocation = getContainerAllocation(costs.CPUReq, costs.CPUUsed)
-			costs.RAMAllocation = getContainerAllocation(costs.RAMReq, costs.RAMUsed)
+			costs.CPUAllocation = getContainerAllocation(costs.CPUReq, costs.CPUUsed, cm.AllocationStrategy)
+			costs.RAMAllocation = getContainerAllocation(costs.RAMReq, costs.RAMUsed, cm.AllocationStrategy)
 			if filterNamespace == "" {
 				containerNameCost[key] = costs
 				missingContainers[key] = costs
@@ -1532,6 +1624,7 @@ func (cm *CostModel) ComputeCostDataRange(cli prometheusClient.Client, clientset
 			return nil, err
 		}
 	}
+	applyCostCenters(containerNameCost, costCenters)
 
 	return containerNameCost, err
 }
@@ -1545,6 +1638,63 @@ func getNamespaceLabels(cache ClusterCache) (map[string]map[string]string, error
 	return nsToLabels, nil
 }
 
+// getNamespaceAnnotations returns the allowed annotations of every namespace.
+func getNamespaceAnnotations(cache ClusterCache, allowlist annotationsAllowlist) (map[string]map[string]string, error) {
+	nsToAnnotations := make(map[string]map[string]string)
+	nss := cache.GetAllNamespaces()
+	for _, ns := range nss {
+		nsToAnnotations[ns.Name] = allowlist.filter(ns.Annotations)
+	}
+	return nsToAnnotations, nil
+}
+
+// withNamespaceAnnotations returns a new map of the pod annotations, completed with the namespace annotations the pod
+// does not set itself. The annotations of deleted pods are not kept by Prometheus, so theirs are nil.
+func withNamespaceAnnotations(podAnnotations map[string]string, nsAnnotations map[string]string) map[string]string {
+	annotations := make(map[string]string, len(podAnnotations)+len(nsAnnotations))
+	for k, v := range nsAnnotations {
+		annotations[k] = v
+	}
+	for k, v := range podAnnotations {
+		annotations[k] = v
+	}
+	return annotations
+}
+
+// annotationsAllowlist is the set of annotations copied into CostData. Annotations are unbounded in size, so only
+// allowed ones are kept. Entries ending in "*" match any annotation with that prefix.
+type annotationsAllowlist []string
+
+func newAnnotationsAllowlist(allowlist string) annotationsAllowlist {
+	var result annotationsAllowlist
+	for _, a := range strings.Split(allowlist, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+func (al annotationsAllowlist) allowed(name string) bool {
+	for _, a := range al {
+		if a == name || (strings.HasSuffix(a, "*") && strings.HasPrefix(name, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns a new map holding only the allowed annotations.
+func (al annotationsAllowlist) filter(annotations map[string]string) map[string]string {
+	filtered := make(map[string]string)
+	for k, v := range annotations {
+		if al.allowed(k) {
+			filtered[k] = v
+		}
+	}
+	return filtered
+}
+
 func getDaemonsetsOfPod(pod v1.Pod) []string {
 	for _, ownerReference := range pod.ObjectMeta.OwnerReferences {
 		if ownerReference.Kind == "DaemonSet" {
@@ -1563,6 +1713,31 @@ func getJobsOfPod(pod v1.Pod) []string {
 	return []string{}
 }
 
+// getJobCronJobs maps each job created by a CronJob to the name of that CronJob.
+func getJobCronJobs(cache ClusterCache) (map[string]map[string]string, error) {
+	jobCronJobsMapping := make(map[string]map[string]string) // namespace: jobName: cronJobName
+	for _, job := range cache.GetAllJobs() {
+		for _, ownerReference := range job.ObjectMeta.OwnerReferences {
+			if ownerReference.Kind == "CronJob" {
+				if _, ok := jobCronJobsMapping[job.Namespace]; !ok {
+					jobCronJobsMapping[job.Namespace] = make(map[string]string)
+				}
+				jobCronJobsMapping[job.Namespace][job.Name] = ownerReference.Name
+			}
+		}
+	}
+	return jobCronJobsMapping, nil
+}
+
+func getCronJobsOfPod(pod v1.Pod, jobCronJobsMapping map[string]map[string]string) []string {
+	for _, job := range getJobsOfPod(pod) {
+		if cronJob, ok := jobCronJobsMapping[pod.Namespace][job]; ok {
+			return []string{cronJob}
+		}
+	}
+	return []string{}
+}
+
 func getStatefulSetsOfPod(pod v1.Pod) []string {
 	for _, ownerReference := range pod.ObjectMeta.OwnerReferences {
 		if ownerReference.Kind == "StatefulSet" {
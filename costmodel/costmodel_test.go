@@ -0,0 +1,42 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestWithNamespaceAnnotations(t *testing.T) {
+	ns := map[string]string{"owner": "team-a", "cost-center": "cc1"}
+	cases := []struct {
+		name     string
+		pod      map[string]string
+		expected map[string]string
+	}{
+		{"deleted pod", nil, map[string]string{"owner": "team-a", "cost-center": "cc1"}},
+		{"pod override", map[string]string{"owner": "team-b", "team": "web"}, map[string]string{"owner": "team-b", "cost-center": "cc1", "team": "web"}},
+	}
+	for _, c := range cases {
+		annotations := withNamespaceAnnotations(c.pod, ns)
+		if len(annotations) != len(c.expected) {
+			t.Errorf("%s: annotations are %v, expected %v", c.name, annotations, c.expected)
+		}
+		for k, v := range c.expected {
+			if annotations[k] != v {
+				t.Errorf("%s: annotation %s is %q, expected %q", c.name, k, annotations[k], v)
+			}
+		}
+	}
+	if ns["owner"] != "team-a" || len(ns) != 2 {
+		t.Errorf("The namespace annotations were modified: %v", ns)
+	}
+
+	// A deleted container is aggregated by the annotations of its namespace like a running one.
+	node := testNode(4, 4)
+	running := testContainer("cluster-one", node, "node-a", "ns1", 1, 1)
+	running.Annotations = withNamespaceAnnotations(map[string]string{}, ns)
+	deleted := testContainer("cluster-one", node, "node-b", "ns1", 2, 1)
+	deleted.Annotations = withNamespaceAnnotations(nil, ns)
+	agg := AggregateCostModel(map[string]*CostData{"running": running, "deleted": deleted}, "annotation", "owner", nil)
+	if len(agg) != 1 || agg["team-a"] == nil || !approxEqual(agg["team-a"].CPUCost, 3) {
+		t.Errorf("Aggregations by owner are %v, expected team-a with a CPU cost of 3", agg)
+	}
+}
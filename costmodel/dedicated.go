@@ -0,0 +1,283 @@
+package costmodel
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	costAnalyzerCloud "github.com/kubecost/cost-model/cloud"
+	prometheusClient "github.com/prometheus/client_golang/api"
+)
+
+// DedicatedKey prefixes the aggregation keys of tenants charged for dedicated nodes, so that they never merge with
+// the aggregation of a namespace, label value or other field that happens to share the tenant's name.
+const DedicatedKey = "__dedicated__"
+
+// dedicatedTenant returns the tenant a container's node is dedicated to, or "" if the node is not dedicated.
+func dedicatedTenant(costDatum *CostData) string {
+	if costDatum.NodeData == nil {
+		return ""
+	}
+	return costDatum.NodeData.Tenant
+}
+
+// splitDedicatedCostData separates the containers running on dedicated nodes from the others. It also returns the
+// tenant of every dedicated node, keyed by idleGroupKey.
+func splitDedicatedCostData(costData map[string]*CostData) (map[string]*CostData, map[string]*CostData, map[string]string) {
+	others := make(map[string]*CostData)
+	dedicated := make(map[string]*CostData)
+	tenants := make(map[string]string)
+	for key, costDatum := range costData {
+		if tenant := dedicatedTenant(costDatum); tenant != "" {
+			dedicated[key] = costDatum
+			tenants[idleGroupKey(costDatum, true)] = tenant
+		} else {
+			others[key] = costDatum
+		}
+	}
+	return others, dedicated, tenants
+}
+
+// queryDedicatedNodesStr reports when each of a set of nodes was up, from the capacity kube-state-metrics exports
+// for every node whether or not it runs containers.
+const queryDedicatedNodesStr = `avg(avg_over_time(kube_node_status_capacity_cpu_cores{node=~"%s"}[%s] %s)) by (node)`
+
+// DedicatedNode is a node dedicated to a tenant with the hourly cost of its whole capacity, before discount, at every
+// timestamp it was up. It lets AggregateCostModel charge tenants for dedicated nodes that ran no containers.
+type DedicatedNode struct {
+	Cluster       string
+	Name          string
+	Tenant        string
+	CPUCostVector []*Vector
+	RAMCostVector []*Vector
+	GPUCostVector []*Vector
+}
+
+// tenantLevelIndex returns the level tenants are reported under: tenantLevel if the aggregation has it, else the
+// namespace level, else the first level.
+func tenantLevelIndex(levels []aggregationLevel, tenantLevel string) int {
+	namespace := -1
+	for i, level := range levels {
+		if tenantLevel != "" && level.String() == tenantLevel {
+			return i
+		}
+		if namespace < 0 && level.Field == "namespace" {
+			namespace = i
+		}
+	}
+	if namespace >= 0 {
+		return namespace
+	}
+	return 0
+}
+
+// dedicatedAggregationKey returns the key a tenant's dedicated nodes are aggregated under, e.g. "__dedicated__|acme".
+// The tenant is reported at its level, see tenantLevelIndex, and every other level is DedicatedKey, e.g.
+// "__dedicated__|acme|__dedicated__" for "cluster,namespace,node". As for aggregationKeys, multi-level aggregations
+// also get the value at each level by level name.
+func dedicatedAggregationKey(tenant string, levels []aggregationLevel, tenantLevel string) (string, map[string]string) {
+	if len(levels) < 2 {
+		return DedicatedKey + AggregationKeySeparator + tenant, nil
+	}
+	values := make([]string, len(levels))
+	properties := make(map[string]string)
+	tenantIndex := tenantLevelIndex(levels, tenantLevel)
+	for i, level := range levels {
+		values[i] = DedicatedKey
+		if i == tenantIndex {
+			values[i] = tenant
+		}
+		properties[level.String()] = values[i]
+	}
+	return strings.Join(values, AggregationKeySeparator), properties
+}
+
+// chargeDedicatedNodes adds the cost of each dedicated node not charged for its containers to the tenant's
+// aggregation, so that the tenant is charged the node's full hourly cost. Nodes of dedicatedNodes that ran no
+// containers are charged their whole cost vectors.
+func chargeDedicatedNodes(nodes map[string]*idleCosts, tenants map[string]string, dedicatedNodes map[string]*DedicatedNode, discount float64, aggregations map[string]*Aggregation, aggregator string, aggregatorSubField string, levels []aggregationLevel, tenantLevel string) {
+	tenantAggregation := func(tenant string, cluster string) *Aggregation {
+		aggKey, properties := dedicatedAggregationKey(tenant, levels, tenantLevel)
+		agg, ok := aggregations[aggKey]
+		if !ok {
+			agg = &Aggregation{
+				Aggregator:         aggregator,
+				AggregatorSubField: aggregatorSubField,
+				Environment:        aggKey,
+				Cluster:            cluster,
+				Properties:         properties,
+			}
+			aggregations[aggKey] = agg
+		}
+		return agg
+	}
+	for key, n := range nodes {
+		agg := tenantAggregation(tenants[key], n.Cluster)
+		timestamps := idleTimestamps(n.cpuCharged, n.ramCharged, n.gpuCharged)
+		agg.CPUCostVector = addVectors(idleVector(n.cpuCapacity, n.cpuCharged, timestamps), agg.CPUCostVector)
+		agg.RAMCostVector = addVectors(idleVector(n.ramCapacity, n.ramCharged, timestamps), agg.RAMCostVector)
+		agg.GPUCostVector = addVectors(idleVector(n.gpuCapacity, n.gpuCharged, timestamps), agg.GPUCostVector)
+	}
+	for key, dn := range dedicatedNodes {
+		if _, ok := nodes[key]; ok {
+			continue
+		}
+		agg := tenantAggregation(dn.Tenant, dn.Cluster)
+		agg.CPUCostVector = addVectors(discountVector(dn.CPUCostVector, discount), agg.CPUCostVector)
+		agg.RAMCostVector = addVectors(discountVector(dn.RAMCostVector, discount), agg.RAMCostVector)
+		agg.GPUCostVector = addVectors(discountVector(dn.GPUCostVector, discount), agg.GPUCostVector)
+	}
+}
+
+// discountVector returns a copy of the cost vectors with the discount applied.
+func discountVector(vectors []*Vector, discount float64) []*Vector {
+	discounted := make([]*Vector, 0, len(vectors))
+	for _, v := range vectors {
+		discounted = append(discounted, &Vector{
+			Timestamp: v.Timestamp,
+			Value:     v.Value * (1 - discount),
+		})
+	}
+	return discounted
+}
+
+// ComputeDedicatedNodes returns the nodes dedicated to a tenant that were up during the window, keyed by cluster and
+// node, priced as in ComputeCostData.
+func (cm *CostModel) ComputeDedicatedNodes(cli prometheusClient.Client, cloud costAnalyzerCloud.Provider, window string, offset string) (map[string]*DedicatedNode, error) {
+	return cm.computeDedicatedNodes(cloud, func(names string) (interface{}, error) {
+		return Query(cli, fmt.Sprintf(queryDedicatedNodesStr, names, window, offset))
+	})
+}
+
+// ComputeDedicatedNodesRange returns the nodes dedicated to a tenant that were up between start and end, keyed by
+// cluster and node, with one cost vector per window as in ComputeCostDataRange.
+func (cm *CostModel) ComputeDedicatedNodesRange(cli prometheusClient.Client, cloud costAnalyzerCloud.Provider, startString, endString, windowString string) (map[string]*DedicatedNode, error) {
+	layout := "2006-01-02T15:04:05.000Z"
+	start, err := time.Parse(layout, startString)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(layout, endString)
+	if err != nil {
+		return nil, err
+	}
+	window, err := time.ParseDuration(windowString)
+	if err != nil {
+		return nil, err
+	}
+	return cm.computeDedicatedNodes(cloud, func(names string) (interface{}, error) {
+		return QueryRange(cli, fmt.Sprintf(queryDedicatedNodesStr, names, windowString, ""), start, end, window)
+	})
+}
+
+// computeDedicatedNodes prices the capacity of every dedicated node at each timestamp query reports it up.
+func (cm *CostModel) computeDedicatedNodes(cloud costAnalyzerCloud.Provider, query func(names string) (interface{}, error)) (map[string]*DedicatedNode, error) {
+	nodes, err := getNodeCost(cm.Cache, cloud)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name, n := range nodes {
+		if n != nil && n.Tenant != "" {
+			names = append(names, name)
+		}
+	}
+	dedicatedNodes := make(map[string]*DedicatedNode)
+	if len(names) == 0 {
+		return dedicatedNodes, nil
+	}
+	result, err := query(strings.Join(names, "|"))
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := getNodeVectors(result)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterID := os.Getenv(CLUSTER_ID)
+	for name, vectors := range uptime {
+		n, ok := nodes[name]
+		if !ok || n == nil || n.Tenant == "" {
+			continue
+		}
+		cpu, err := strconv.ParseFloat(n.VCPU, 64)
+		if err != nil {
+			continue
+		}
+		ramBytes, err := strconv.ParseFloat(n.RAMBytes, 64)
+		if err != nil {
+			continue
+		}
+		gpu, _ := strconv.ParseFloat(n.GPU, 64)
+		cpuCost, _ := strconv.ParseFloat(n.VCPUCost, 64)
+		ramCost, _ := strconv.ParseFloat(n.RAMCost, 64)
+		gpuCost, _ := strconv.ParseFloat(n.GPUCost, 64)
+		dn := &DedicatedNode{
+			Cluster: clusterID,
+			Name:    name,
+			Tenant:  n.Tenant,
+		}
+		for _, v := range vectors {
+			dn.CPUCostVector = append(dn.CPUCostVector, &Vector{Timestamp: v.Timestamp, Value: cpu * cpuCost})
+			dn.RAMCostVector = append(dn.RAMCostVector, &Vector{Timestamp: v.Timestamp, Value: (ramBytes / 1024 / 1024 / 1024) * ramCost})
+			dn.GPUCostVector = append(dn.GPUCostVector, &Vector{Timestamp: v.Timestamp, Value: gpu * gpuCost})
+		}
+		dedicatedNodes[clusterID+","+name] = dn
+	}
+	return dedicatedNodes, nil
+}
+
+// getNodeVectors reads the vectors of a query aggregated by node, from either an instant or a range query.
+func getNodeVectors(qr interface{}) (map[string][]*Vector, error) {
+	data, ok := qr.(map[string]interface{})["data"]
+	if !ok {
+		e, err := wrapPrometheusError(qr)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf(e)
+	}
+	results, ok := data.(map[string]interface{})["result"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Improperly formatted results from prometheus, result field is not a slice")
+	}
+	nodeData := make(map[string][]*Vector)
+	for _, val := range results {
+		metric, ok := val.(map[string]interface{})["metric"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Prometheus vector does not have metric labels")
+		}
+		node, ok := metric["node"].(string)
+		if !ok {
+			return nil, fmt.Errorf("Prometheus vector does not have a node label")
+		}
+		var values []interface{}
+		if vs, ok := val.(map[string]interface{})["values"].([]interface{}); ok {
+			values = vs
+		} else if v, ok := val.(map[string]interface{})["value"]; ok {
+			values = []interface{}{v}
+		} else {
+			return nil, fmt.Errorf("Improperly formatted results from prometheus, vector has no value")
+		}
+		var vectors []*Vector
+		for _, value := range values {
+			dataPoint, ok := value.([]interface{})
+			if !ok || len(dataPoint) != 2 {
+				return nil, fmt.Errorf("Improperly formatted datapoint from Prometheus")
+			}
+			strVal, _ := dataPoint[1].(string)
+			v, _ := strconv.ParseFloat(strVal, 64)
+			timestamp, _ := dataPoint[0].(float64)
+			vectors = append(vectors, &Vector{
+				Timestamp: math.Round(timestamp/10) * 10,
+				Value:     v,
+			})
+		}
+		nodeData[node] = vectors
+	}
+	return nodeData, nil
+}
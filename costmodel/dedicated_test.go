@@ -0,0 +1,156 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestAggregateCostModelDedicatedNodes(t *testing.T) {
+	dedicatedNode := testNode(4, 4)
+	dedicatedNode.Tenant = "ns1"
+	sharedNode := testNode(4, 4)
+	costData := map[string]*CostData{
+		"dedicated": testContainer("cluster-one", dedicatedNode, "node-a", "ns2", 1, 1),
+		"shared":    testContainer("cluster-one", sharedNode, "node-b", "ns1", 1, 1),
+	}
+
+	cases := []struct {
+		aggregation  string
+		tenantLevel  string
+		dedicatedKey string
+		sharedKey    string
+		properties   map[string]string
+	}{
+		{"namespace", "", "__dedicated__|ns1", "ns1", nil},
+		{"cluster", "", "__dedicated__|ns1", "cluster-one", nil},
+		{"cluster,namespace", "", "__dedicated__|ns1", "cluster-one|ns1", map[string]string{"cluster": DedicatedKey, "namespace": "ns1"}},
+		{"namespace,cluster", "", "ns1|__dedicated__", "ns1|cluster-one", map[string]string{"namespace": "ns1", "cluster": DedicatedKey}},
+		{"cluster,namespace,node", "", "__dedicated__|ns1|__dedicated__", "cluster-one|ns1|node-b", map[string]string{"cluster": DedicatedKey, "namespace": "ns1", "node": DedicatedKey}},
+		{"cluster,node", "", "ns1|__dedicated__", "cluster-one|node-b", map[string]string{"cluster": "ns1", "node": DedicatedKey}},
+		{"namespace,label:team", "label:team", "__dedicated__|ns1", "ns1|__unallocated__", map[string]string{"namespace": DedicatedKey, "label:team": "ns1"}},
+		{"label,namespace", "label:team", "ns1|__dedicated__", "__unallocated__|ns1", map[string]string{"label:team": "ns1", "namespace": DedicatedKey}},
+	}
+	for _, c := range cases {
+		agg := AggregateCostModel(costData, c.aggregation, "team", &AggregationOptions{DedicatedNodes: true, TenantLevel: c.tenantLevel})
+		if len(agg) != 2 {
+			t.Errorf("%s: got %d aggregations, expected 2", c.aggregation, len(agg))
+		}
+		dedicated, ok := agg[c.dedicatedKey]
+		if !ok {
+			t.Errorf("%s: missing aggregation %s", c.aggregation, c.dedicatedKey)
+			continue
+		}
+		// The tenant is charged the whole node, whatever its containers were allocated.
+		if !approxEqual(dedicated.CPUCost, 4) || !approxEqual(dedicated.RAMCost, 4) {
+			t.Errorf("%s: dedicated CPU and RAM costs are %f and %f, expected 4 and 4", c.aggregation, dedicated.CPUCost, dedicated.RAMCost)
+		}
+		if len(dedicated.Properties) != len(c.properties) {
+			t.Errorf("%s: dedicated properties are %v, expected %v", c.aggregation, dedicated.Properties, c.properties)
+		}
+		for k, v := range c.properties {
+			if dedicated.Properties[k] != v {
+				t.Errorf("%s: dedicated properties are %v, expected %v", c.aggregation, dedicated.Properties, c.properties)
+				break
+			}
+		}
+		shared, ok := agg[c.sharedKey]
+		if !ok {
+			t.Errorf("%s: missing aggregation %s", c.aggregation, c.sharedKey)
+			continue
+		}
+		if !approxEqual(shared.CPUCost, 1) {
+			t.Errorf("%s: CPU cost of %s is %f, expected 1", c.aggregation, c.sharedKey, shared.CPUCost)
+		}
+	}
+}
+
+func TestAggregateCostModelDedicatedNodesWithoutContainers(t *testing.T) {
+	dedicatedNode := testNode(4, 4)
+	dedicatedNode.Tenant = "ns1"
+	costData := map[string]*CostData{
+		"dedicated": testContainer("cluster-one", dedicatedNode, "node-a", "ns2", 1, 1),
+	}
+	dedicatedNodes := map[string]*DedicatedNode{
+		// Already charged in full from its containers.
+		"cluster-one,node-a": {
+			Cluster:       "cluster-one",
+			Name:          "node-a",
+			Tenant:        "ns1",
+			CPUCostVector: testVectors(4),
+			RAMCostVector: testVectors(4),
+		},
+		// Ran no containers for two hours.
+		"cluster-one,node-b": {
+			Cluster:       "cluster-one",
+			Name:          "node-b",
+			Tenant:        "ns1",
+			CPUCostVector: testVectors(2, 2),
+			RAMCostVector: testVectors(1, 1),
+			GPUCostVector: testVectors(1, 1),
+		},
+		"cluster-one,node-c": {
+			Cluster:       "cluster-one",
+			Name:          "node-c",
+			Tenant:        "ns3",
+			CPUCostVector: testVectors(2),
+		},
+	}
+	agg := AggregateCostModel(costData, "namespace", "", &AggregationOptions{DedicatedNodes: true, DedicatedNodeCosts: dedicatedNodes, Discount: 0.5})
+	if len(agg) != 2 {
+		t.Errorf("Got %d aggregations, expected 2", len(agg))
+	}
+	// The discount applies to the cost of the nodes without containers too.
+	if a, ok := agg["__dedicated__|ns1"]; !ok {
+		t.Errorf("Missing aggregation __dedicated__|ns1")
+	} else if !approxEqual(a.CPUCost, 2+2) || !approxEqual(a.RAMCost, 2+1) || !approxEqual(a.GPUCost, 1) {
+		t.Errorf("CPU, RAM and GPU costs of ns1 are %f, %f and %f, expected 4, 3 and 1", a.CPUCost, a.RAMCost, a.GPUCost)
+	}
+	if a, ok := agg["__dedicated__|ns3"]; !ok {
+		t.Errorf("Missing aggregation __dedicated__|ns3")
+	} else if !approxEqual(a.CPUCost, 1) || a.Cluster != "cluster-one" {
+		t.Errorf("ns3 costs %f in %s, expected 1 in cluster-one", a.CPUCost, a.Cluster)
+	}
+
+	// Without dedicated nodes, nothing is charged for them.
+	agg = AggregateCostModel(costData, "namespace", "", &AggregationOptions{DedicatedNodeCosts: dedicatedNodes})
+	if _, ok := agg["__dedicated__|ns3"]; ok || len(agg) != 1 {
+		t.Errorf("Got aggregations %v, expected only ns2", agg)
+	}
+}
+
+func TestGetNodeVectors(t *testing.T) {
+	instant := map[string]interface{}{
+		"data": map[string]interface{}{
+			"result": []interface{}{
+				map[string]interface{}{
+					"metric": map[string]interface{}{"node": "node-a"},
+					"value":  []interface{}{float64(3601), "4"},
+				},
+			},
+		},
+	}
+	vectors, err := getNodeVectors(instant)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if v := vectors["node-a"]; len(v) != 1 || v[0].Timestamp != 3600 || v[0].Value != 4 {
+		t.Errorf("Instant vectors are %v, expected one at 3600", v)
+	}
+
+	ranged := map[string]interface{}{
+		"data": map[string]interface{}{
+			"result": []interface{}{
+				map[string]interface{}{
+					"metric": map[string]interface{}{"node": "node-b"},
+					"values": []interface{}{[]interface{}{float64(3600), "2"}, []interface{}{float64(7200), "2"}},
+				},
+			},
+		},
+	}
+	vectors, err = getNodeVectors(ranged)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if v := vectors["node-b"]; len(v) != 2 || v[1].Timestamp != 7200 {
+		t.Errorf("Range vectors are %v, expected two ending at 7200", v)
+	}
+}
@@ -0,0 +1,69 @@
+package costmodel
+
+import (
+	"math"
+	"strconv"
+)
+
+// getWasteVectors prices the CPU and RAM a container requested but did not use, with the same discount and pricing
+// rules as its cost.
+func getWasteVectors(costDatum *CostData, discount float64, rules *PricingRules) ([]*Vector, []*Vector) {
+	if costDatum.NodeData == nil {
+		return nil, nil
+	}
+	multiplier := rules.multiplier(costDatum)
+	cpuCost, _ := strconv.ParseFloat(costDatum.NodeData.VCPUCost, 64)
+	ramCost, _ := strconv.ParseFloat(costDatum.NodeData.RAMCost, 64)
+
+	cpuv := unusedVector(costDatum.CPUReq, costDatum.CPUUsed)
+	for _, v := range cpuv {
+		v.Value = v.Value * cpuCost * (1 - discount) * multiplier
+	}
+	ramv := unusedVector(costDatum.RAMReq, costDatum.RAMUsed)
+	for _, v := range ramv {
+		v.Value = (v.Value / 1024 / 1024 / 1024) * ramCost * (1 - discount) * multiplier
+	}
+	return cpuv, ramv
+}
+
+// unusedVector returns, for each requested timestamp, how much of the request went unused.
+func unusedVector(req []*Vector, used []*Vector) []*Vector {
+	usedMap := make(map[float64]float64)
+	for _, v := range used {
+		usedMap[math.Round(v.Timestamp/10)*10] += v.Value
+	}
+	unused := make([]*Vector, 0, len(req))
+	for _, v := range req {
+		if v.Timestamp == 0 {
+			continue
+		}
+		t := math.Round(v.Timestamp/10) * 10
+		unused = append(unused, &Vector{
+			Timestamp: t,
+			Value:     math.Max(v.Value-usedMap[t], 0),
+		})
+	}
+	return unused
+}
+
+// computeEfficiency sets the efficiency and wasted cost of an aggregation from its merged request, usage and
+// allocation vectors. Efficiencies are left at zero when there is nothing to divide by.
+func computeEfficiency(agg *Aggregation) {
+	cpuUsed := totalVector(agg.CPUUsed)
+	ramUsed := totalVector(agg.RAMUsed)
+	agg.CPURequestEfficiency = ratio(cpuUsed, totalVector(agg.CPURequested))
+	agg.CPUAllocationEfficiency = ratio(cpuUsed, totalVector(agg.CPUAllocation))
+	agg.RAMRequestEfficiency = ratio(ramUsed, totalVector(agg.RAMRequested))
+	agg.RAMAllocationEfficiency = ratio(ramUsed, totalVector(agg.RAMAllocation))
+
+	agg.CPUWastedCost = totalVector(agg.CPUWasteVector)
+	agg.RAMWastedCost = totalVector(agg.RAMWasteVector)
+	agg.WastedCost = agg.CPUWastedCost + agg.RAMWastedCost
+}
+
+func ratio(numerator float64, denominator float64) float64 {
+	if denominator == 0 {
+		return 0
+	}
+	return numerator / denominator
+}
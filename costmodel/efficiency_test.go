@@ -0,0 +1,37 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestGetWasteVectorsPricingRules(t *testing.T) {
+	costDatum := testContainer("cluster-one", testNode(4, 4), "node-a", "ns1", 2, 2)
+	costDatum.QoSClass = "BestEffort"
+	costDatum.CPUReq = testVectors(2)
+	costDatum.CPUUsed = testVectors(0.5)
+	costDatum.RAMReq = testVectors(2 * gib)
+	costDatum.RAMUsed = testVectors(1 * gib)
+	rules := &PricingRules{
+		QoSClasses: map[string]float64{"BestEffort": 0.5},
+	}
+
+	cpuWaste, ramWaste := getWasteVectors(costDatum, 0.2, rules)
+	if waste := totalVector(cpuWaste); !approxEqual(waste, 1.5*0.8*0.5) {
+		t.Errorf("CPU waste is %f, expected %f", waste, 1.5*0.8*0.5)
+	}
+	if waste := totalVector(ramWaste); !approxEqual(waste, 1*0.8*0.5) {
+		t.Errorf("RAM waste is %f, expected %f", waste, 1*0.8*0.5)
+	}
+
+	agg := AggregateCostModel(map[string]*CostData{"a": costDatum}, "namespace", "", &AggregationOptions{
+		Discount: 0.2,
+		Pricing:  rules,
+	})
+	// Nothing is allocated beyond the requests, so the waste is the unused fraction of the cost.
+	if !approxEqual(agg["ns1"].CPUWastedCost, agg["ns1"].CPUCost*0.75) {
+		t.Errorf("CPU wasted cost is %f, expected three quarters of the CPU cost %f", agg["ns1"].CPUWastedCost, agg["ns1"].CPUCost)
+	}
+	if !approxEqual(agg["ns1"].RAMWastedCost, agg["ns1"].RAMCost*0.5) {
+		t.Errorf("RAM wasted cost is %f, expected half the RAM cost %f", agg["ns1"].RAMWastedCost, agg["ns1"].RAMCost)
+	}
+}
@@ -0,0 +1,139 @@
+package costmodel
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	secondsPerDay = 24 * 60 * 60
+	// forecastZ is the z-score of the forecast confidence bounds, 95%.
+	forecastZ = 1.96
+)
+
+// ForecastPoint is the forecast cost of one UTC day.
+type ForecastPoint struct {
+	Timestamp float64 `json:"timestamp"`
+	Cost      float64 `json:"cost"`
+	Lower     float64 `json:"lower"`
+	Upper     float64 `json:"upper"`
+}
+
+// Forecast is the daily cost forecast of one aggregation key, with 95% confidence bounds.
+type Forecast struct {
+	Key        string           `json:"key"`
+	DailyTrend float64          `json:"dailyTrend"` // change in daily cost per day
+	TotalCost  float64          `json:"totalCost"`
+	TotalLower float64          `json:"totalLower"`
+	TotalUpper float64          `json:"totalUpper"`
+	Points     []*ForecastPoint `json:"points"`
+}
+
+// ForecastAggregations forecasts the daily cost of each aggregation over the given number of days after end. The
+// aggregations must be built from hourly cost data ending at end, a UTC midnight, so that every day is complete.
+func ForecastAggregations(aggregations map[string]*Aggregation, end time.Time, days int) (map[string]*Forecast, error) {
+	if err := ComputeTimeSeries(aggregations, ResolutionDaily); err != nil {
+		return nil, err
+	}
+	forecasts := make(map[string]*Forecast, len(aggregations))
+	for key, agg := range aggregations {
+		var history []*CostTimeSeriesPoint
+		for _, p := range agg.TimeSeries {
+			if p.Timestamp < float64(end.Unix()) {
+				history = append(history, p)
+			}
+		}
+		if len(history) == 0 {
+			continue
+		}
+		forecast := forecastDailyCosts(history, days)
+		forecast.Key = key
+		forecasts[key] = forecast
+	}
+	return forecasts, nil
+}
+
+// forecastDailyCosts fits a linear trend to the daily costs by least squares, then a weekly seasonality to what the
+// trend leaves unexplained. Seasonality needs at least two weeks of history. The bounds of each day are the
+// prediction interval of the regression. The bounds of the total assume daily errors to be independent.
+func forecastDailyCosts(history []*CostTimeSeriesPoint, days int) *Forecast {
+	n := float64(len(history))
+	first := history[0].Timestamp
+	xs := make([]float64, len(history))
+	meanX, meanY := 0.0, 0.0
+	for i, p := range history {
+		xs[i] = (p.Timestamp - first) / secondsPerDay
+		meanX += xs[i] / n
+		meanY += p.TotalCost / n
+	}
+	sxx, sxy := 0.0, 0.0
+	for i, p := range history {
+		sxx += (xs[i] - meanX) * (xs[i] - meanX)
+		sxy += (xs[i] - meanX) * (p.TotalCost - meanY)
+	}
+	slope := 0.0
+	if sxx > 0 {
+		slope = sxy / sxx
+	}
+	intercept := meanY - slope*meanX
+
+	var season [7]float64
+	params := 2.0
+	if len(history) >= 14 {
+		var sums, counts [7]float64
+		for i, p := range history {
+			wd := weekday(p.Timestamp)
+			sums[wd] += p.TotalCost - (intercept + slope*xs[i])
+			counts[wd]++
+		}
+		mean := 0.0
+		for wd := range season {
+			if counts[wd] > 0 {
+				season[wd] = sums[wd] / counts[wd]
+			}
+			mean += season[wd] / 7
+		}
+		for wd := range season {
+			season[wd] -= mean
+		}
+		params += 6
+	}
+
+	sse := 0.0
+	for i, p := range history {
+		r := p.TotalCost - (intercept + slope*xs[i] + season[weekday(p.Timestamp)])
+		sse += r * r
+	}
+	sigma := math.Sqrt(sse / math.Max(n-params, 1))
+
+	forecast := &Forecast{
+		DailyTrend: slope,
+		Points:     make([]*ForecastPoint, 0, days),
+	}
+	last := history[len(history)-1].Timestamp
+	for d := 1; d <= days; d++ {
+		t := last + float64(d)*secondsPerDay
+		x := (t - first) / secondsPerDay
+		leverage := 1 / n
+		if sxx > 0 {
+			leverage += (x - meanX) * (x - meanX) / sxx
+		}
+		margin := forecastZ * sigma * math.Sqrt(1+leverage)
+		cost := math.Max(intercept+slope*x+season[weekday(t)], 0)
+		forecast.Points = append(forecast.Points, &ForecastPoint{
+			Timestamp: t,
+			Cost:      cost,
+			Lower:     math.Max(cost-margin, 0),
+			Upper:     cost + margin,
+		})
+		forecast.TotalCost += cost
+	}
+	totalMargin := forecastZ * sigma * math.Sqrt(float64(days))
+	forecast.TotalLower = math.Max(forecast.TotalCost-totalMargin, 0)
+	forecast.TotalUpper = forecast.TotalCost + totalMargin
+	return forecast
+}
+
+func weekday(timestamp float64) int {
+	return int(time.Unix(int64(timestamp), 0).UTC().Weekday())
+}
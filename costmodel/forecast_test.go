@@ -0,0 +1,118 @@
+package costmodel
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// dailyHistory returns one point per day starting at a Monday, with the given total costs.
+func dailyHistory(costs ...float64) []*CostTimeSeriesPoint {
+	start := float64(time.Date(2019, 9, 2, 0, 0, 0, 0, time.UTC).Unix())
+	history := make([]*CostTimeSeriesPoint, 0, len(costs))
+	for i, c := range costs {
+		history = append(history, &CostTimeSeriesPoint{
+			Timestamp: start + float64(i)*secondsPerDay,
+			TotalCost: c,
+		})
+	}
+	return history
+}
+
+func TestForecastDailyCostsLinear(t *testing.T) {
+	cases := []struct {
+		name      string
+		days      int
+		intercept float64
+		slope     float64
+	}{
+		{"one week rising", 7, 10, 2},
+		{"three weeks rising", 21, 10, 2}, // enough history to fit seasonality, which is flat
+		{"one week falling", 7, 50, -3},
+		{"flat", 10, 5, 0},
+	}
+	for _, c := range cases {
+		costs := make([]float64, c.days)
+		for i := range costs {
+			costs[i] = c.intercept + c.slope*float64(i)
+		}
+		forecast := forecastDailyCosts(dailyHistory(costs...), 5)
+		if !approxEqual(forecast.DailyTrend, c.slope) {
+			t.Errorf("%s: daily trend is %f, expected %f", c.name, forecast.DailyTrend, c.slope)
+		}
+		if len(forecast.Points) != 5 {
+			t.Errorf("%s: got %d points, expected 5", c.name, len(forecast.Points))
+			continue
+		}
+		total := 0.0
+		for d, p := range forecast.Points {
+			expected := math.Max(c.intercept+c.slope*float64(c.days+d), 0)
+			total += expected
+			if !approxEqual(p.Cost, expected) {
+				t.Errorf("%s: cost of day %d is %f, expected %f", c.name, d+1, p.Cost, expected)
+			}
+			// A perfect fit leaves no residual, so the bounds are the forecast itself.
+			if !approxEqual(p.Lower, p.Cost) || !approxEqual(p.Upper, p.Cost) {
+				t.Errorf("%s: bounds of day %d are [%f, %f], expected %f", c.name, d+1, p.Lower, p.Upper, p.Cost)
+			}
+		}
+		if !approxEqual(forecast.TotalCost, total) {
+			t.Errorf("%s: total cost is %f, expected %f", c.name, forecast.TotalCost, total)
+		}
+	}
+}
+
+func TestForecastDailyCostsNoise(t *testing.T) {
+	forecast := forecastDailyCosts(dailyHistory(10, 12, 10, 12, 10, 12, 10, 12), 3)
+	for d, p := range forecast.Points {
+		if p.Lower >= p.Cost || p.Upper <= p.Cost {
+			t.Errorf("Bounds of day %d are [%f, %f] around %f, expected a non-empty interval", d+1, p.Lower, p.Upper, p.Cost)
+		}
+	}
+	if forecast.TotalLower >= forecast.TotalCost || forecast.TotalUpper <= forecast.TotalCost {
+		t.Errorf("Total bounds are [%f, %f] around %f, expected a non-empty interval", forecast.TotalLower, forecast.TotalUpper, forecast.TotalCost)
+	}
+}
+
+func TestForecastAggregationsShortHistory(t *testing.T) {
+	end := time.Date(2019, 9, 3, 0, 0, 0, 0, time.UTC)
+	day := float64(end.Unix()) - secondsPerDay
+	aggregations := map[string]*Aggregation{
+		// A single day of history forecasts that day's cost, with no trend.
+		"single": {
+			CPUCostVector: []*Vector{{Timestamp: day + 3600, Value: 4}, {Timestamp: day + 7200, Value: 2}},
+		},
+		// Costs after end are not history, so nothing is forecast.
+		"none": {
+			CPUCostVector: []*Vector{{Timestamp: float64(end.Unix()) + 3600, Value: 4}},
+		},
+	}
+	forecasts, err := ForecastAggregations(aggregations, end, 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if _, ok := forecasts["none"]; ok {
+		t.Errorf("Expected no forecast without history")
+	}
+	single, ok := forecasts["single"]
+	if !ok {
+		t.Fatalf("Missing forecast of a single day of history")
+	}
+	if single.DailyTrend != 0 {
+		t.Errorf("Daily trend of a single day is %f, expected 0", single.DailyTrend)
+	}
+	if len(single.Points) != 3 {
+		t.Fatalf("Got %d points, expected 3", len(single.Points))
+	}
+	for d, p := range single.Points {
+		if !approxEqual(p.Cost, 6) || !approxEqual(p.Lower, 6) || !approxEqual(p.Upper, 6) {
+			t.Errorf("Day %d forecast %f in [%f, %f], expected 6", d+1, p.Cost, p.Lower, p.Upper)
+		}
+		if expected := day + float64(d+1)*secondsPerDay; p.Timestamp != expected {
+			t.Errorf("Day %d is at %f, expected %f", d+1, p.Timestamp, expected)
+		}
+	}
+	if !approxEqual(single.TotalCost, 18) {
+		t.Errorf("Total cost is %f, expected 18", single.TotalCost)
+	}
+}
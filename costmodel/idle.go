@@ -0,0 +1,188 @@
+package costmodel
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IdleKey is the aggregation key under which unallocated node capacity is reported.
+const IdleKey = "__idle__"
+
+// idleCosts holds the idle cost vectors of a single node, or of all the nodes of a cluster, along with the
+// allocated cost vectors they were derived from. The charged vectors are the allocated costs after pricing rules.
+type idleCosts struct {
+	Cluster       string
+	Node          string
+	CPUCostVector []*Vector
+	RAMCostVector []*Vector
+	GPUCostVector []*Vector
+	cpuAllocated  []*Vector
+	ramAllocated  []*Vector
+	gpuAllocated  []*Vector
+	cpuCharged    []*Vector
+	ramCharged    []*Vector
+	gpuCharged    []*Vector
+	cpuCapacity   float64
+	ramCapacity   float64
+	gpuCapacity   float64
+}
+
+// idleGroupKey returns the key used to group containers when computing idle, either by cluster or by cluster and node.
+func idleGroupKey(costDatum *CostData, byNode bool) string {
+	if byNode {
+		return costDatum.ClusterID + "," + costDatum.NodeName
+	}
+	return costDatum.ClusterID
+}
+
+// idleAggregationKey returns the aggregation key for an idle bucket, e.g. "__idle__", "__idle__/cluster-one" or
+// "__idle__/cluster-one/node-a".
+func idleAggregationKey(cluster string, node string) string {
+	parts := []string{IdleKey}
+	if cluster != "" {
+		parts = append(parts, cluster)
+	}
+	if node != "" {
+		parts = append(parts, node)
+	}
+	return strings.Join(parts, "/")
+}
+
+// computeIdleCosts compares the hourly cost of each node's CPU, RAM and GPU capacity against the cost allocated to
+// the containers running on it. Nodes with missing capacity data (for instance deleted nodes) are skipped.
+// Results are keyed by idleGroupKey. Idle is computed before pricing rules, so that a container charged less than
+// its allocation does not turn the difference into idle.
+func computeIdleCosts(costData map[string]*CostData, discount float64, rules *PricingRules, byNode bool) map[string]*idleCosts {
+	nodes := make(map[string]*idleCosts)
+	for _, costDatum := range costData {
+		if costDatum.NodeData == nil {
+			continue
+		}
+		key := idleGroupKey(costDatum, true)
+		n, ok := nodes[key]
+		if !ok {
+			cpu, err := strconv.ParseFloat(costDatum.NodeData.VCPU, 64)
+			if err != nil {
+				continue
+			}
+			ramBytes, err := strconv.ParseFloat(costDatum.NodeData.RAMBytes, 64)
+			if err != nil {
+				continue
+			}
+			gpu, _ := strconv.ParseFloat(costDatum.NodeData.GPU, 64)
+			cpuCost, _ := strconv.ParseFloat(costDatum.NodeData.VCPUCost, 64)
+			ramCost, _ := strconv.ParseFloat(costDatum.NodeData.RAMCost, 64)
+			gpuCost, _ := strconv.ParseFloat(costDatum.NodeData.GPUCost, 64)
+			n = &idleCosts{
+				Cluster:     costDatum.ClusterID,
+				Node:        costDatum.NodeName,
+				cpuCapacity: cpu * cpuCost * (1 - discount),
+				ramCapacity: (ramBytes / 1024 / 1024 / 1024) * ramCost * (1 - discount),
+				gpuCapacity: gpu * gpuCost * (1 - discount),
+			}
+			nodes[key] = n
+		}
+		cpuv, ramv, gpuv, _ := getPriceVectors(costDatum, discount, nil)
+		n.cpuAllocated = addVectors(cpuv, n.cpuAllocated)
+		n.ramAllocated = addVectors(ramv, n.ramAllocated)
+		n.gpuAllocated = addVectors(gpuv, n.gpuAllocated)
+		cpuv, ramv, gpuv, _ = getPriceVectors(costDatum, discount, rules)
+		n.cpuCharged = addVectors(cpuv, n.cpuCharged)
+		n.ramCharged = addVectors(ramv, n.ramCharged)
+		n.gpuCharged = addVectors(gpuv, n.gpuCharged)
+	}
+
+	for _, n := range nodes {
+		timestamps := idleTimestamps(n.cpuAllocated, n.ramAllocated, n.gpuAllocated)
+		n.CPUCostVector = idleVector(n.cpuCapacity, n.cpuAllocated, timestamps)
+		n.RAMCostVector = idleVector(n.ramCapacity, n.ramAllocated, timestamps)
+		n.GPUCostVector = idleVector(n.gpuCapacity, n.gpuAllocated, timestamps)
+	}
+	if byNode {
+		return nodes
+	}
+
+	clusters := make(map[string]*idleCosts)
+	for _, n := range nodes {
+		c, ok := clusters[n.Cluster]
+		if !ok {
+			c = &idleCosts{
+				Cluster: n.Cluster,
+			}
+			clusters[n.Cluster] = c
+		}
+		c.CPUCostVector = addVectors(n.CPUCostVector, c.CPUCostVector)
+		c.RAMCostVector = addVectors(n.RAMCostVector, c.RAMCostVector)
+		c.GPUCostVector = addVectors(n.GPUCostVector, c.GPUCostVector)
+		c.cpuAllocated = addVectors(n.cpuAllocated, c.cpuAllocated)
+		c.ramAllocated = addVectors(n.ramAllocated, c.ramAllocated)
+		c.gpuAllocated = addVectors(n.gpuAllocated, c.gpuAllocated)
+		c.cpuCharged = addVectors(n.cpuCharged, c.cpuCharged)
+		c.ramCharged = addVectors(n.ramCharged, c.ramCharged)
+		c.gpuCharged = addVectors(n.gpuCharged, c.gpuCharged)
+	}
+	return clusters
+}
+
+// idleTimestamps returns the sorted set of non-zero timestamps across the given vectors.
+func idleTimestamps(vectorLists ...[]*Vector) []float64 {
+	seen := make(map[float64]bool)
+	var timestamps []float64
+	for _, vectors := range vectorLists {
+		for _, v := range vectors {
+			if v.Timestamp == 0 || seen[v.Timestamp] {
+				continue
+			}
+			seen[v.Timestamp] = true
+			timestamps = append(timestamps, v.Timestamp)
+		}
+	}
+	sort.Float64s(timestamps)
+	return timestamps
+}
+
+// idleVector returns, for each timestamp, the capacity cost not covered by the allocated cost. Allocation can exceed
+// capacity when usage bursts above requests, in which case idle is zero rather than negative.
+func idleVector(capacity float64, allocated []*Vector, timestamps []float64) []*Vector {
+	allocatedMap := vectorMap(allocated)
+	idle := make([]*Vector, 0, len(timestamps))
+	for _, t := range timestamps {
+		idle = append(idle, &Vector{
+			Timestamp: t,
+			Value:     math.Max(capacity-allocatedMap[t], 0),
+		})
+	}
+	return idle
+}
+
+// shareIdleCoefficients returns, per timestamp, the fraction of charged cost that must be added to each container
+// in order to distribute the idle cost proportionally. The shared amount is removed from the idle vector, so only
+// idle cost that cannot be distributed (because nothing was charged at that timestamp) remains.
+func shareIdleCoefficients(idle []*Vector, charged []*Vector) map[float64]float64 {
+	chargedMap := vectorMap(charged)
+	coefficients := make(map[float64]float64)
+	for _, v := range idle {
+		if a := chargedMap[v.Timestamp]; a > 0 {
+			coefficients[v.Timestamp] = v.Value / a
+			v.Value = 0
+		}
+	}
+	return coefficients
+}
+
+// scaleVectors multiplies each value by one plus the coefficient at its timestamp.
+func scaleVectors(vectors []*Vector, coefficients map[float64]float64) {
+	for _, v := range vectors {
+		v.Value = v.Value * (1 + coefficients[v.Timestamp])
+	}
+}
+
+func vectorMap(vectors []*Vector) map[float64]float64 {
+	m := make(map[float64]float64)
+	for _, v := range vectors {
+		m[v.Timestamp] += v.Value
+	}
+	return m
+}
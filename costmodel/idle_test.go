@@ -0,0 +1,207 @@
+package costmodel
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	costAnalyzerCloud "github.com/kubecost/cost-model/cloud"
+)
+
+const gib = 1024 * 1024 * 1024
+
+// testVectors returns one vector per value, an hour apart.
+func testVectors(values ...float64) []*Vector {
+	vectors := make([]*Vector, 0, len(values))
+	for i, v := range values {
+		vectors = append(vectors, &Vector{
+			Timestamp: float64(3600 * (i + 1)),
+			Value:     v,
+		})
+	}
+	return vectors
+}
+
+// testNode returns a node with the given vCPUs and GiB of RAM, each priced at one per hour.
+func testNode(cpu float64, ramGB float64) *costAnalyzerCloud.Node {
+	return &costAnalyzerCloud.Node{
+		VCPU:     strconv.FormatFloat(cpu, 'f', -1, 64),
+		VCPUCost: "1",
+		RAMBytes: strconv.FormatFloat(ramGB*gib, 'f', -1, 64),
+		RAMCost:  "1",
+		GPU:      "0",
+		GPUCost:  "0",
+	}
+}
+
+// testContainer returns a container allocated the given vCPUs and GiB of RAM over a single hour.
+func testContainer(cluster string, node *costAnalyzerCloud.Node, nodeName string, namespace string, cpu float64, ramGB float64) *CostData {
+	return &CostData{
+		Name:          namespace + "-" + nodeName,
+		PodName:       namespace + "-" + nodeName,
+		Namespace:     namespace,
+		ClusterID:     cluster,
+		NodeName:      nodeName,
+		NodeData:      node,
+		CPUAllocation: testVectors(cpu),
+		RAMAllocation: testVectors(ramGB * gib),
+	}
+}
+
+func approxEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestComputeIdleCosts(t *testing.T) {
+	nodeA := testNode(4, 8)
+	nodeB := testNode(2, 4)
+	costData := map[string]*CostData{
+		"a1": testContainer("cluster-one", nodeA, "node-a", "ns1", 1, 2),
+		"a2": testContainer("cluster-one", nodeA, "node-a", "ns2", 2, 2),
+		"b1": testContainer("cluster-one", nodeB, "node-b", "ns1", 1, 1),
+		"c1": testContainer("cluster-two", nodeB, "node-c", "ns1", 3, 1),
+	}
+
+	cases := []struct {
+		name     string
+		byNode   bool
+		discount float64
+		cpuIdle  map[string]float64
+		ramIdle  map[string]float64
+	}{
+		{
+			name:    "per cluster",
+			cpuIdle: map[string]float64{"cluster-one": 1 + 1, "cluster-two": 0},
+			ramIdle: map[string]float64{"cluster-one": 4 + 3, "cluster-two": 3},
+		},
+		{
+			name:    "per node",
+			byNode:  true,
+			cpuIdle: map[string]float64{"cluster-one,node-a": 1, "cluster-one,node-b": 1, "cluster-two,node-c": 0},
+			ramIdle: map[string]float64{"cluster-one,node-a": 4, "cluster-one,node-b": 3, "cluster-two,node-c": 3},
+		},
+		{
+			name:     "discounted",
+			discount: 0.5,
+			cpuIdle:  map[string]float64{"cluster-one": 1, "cluster-two": 0},
+			ramIdle:  map[string]float64{"cluster-one": 3.5, "cluster-two": 1.5},
+		},
+	}
+	for _, c := range cases {
+		idle := computeIdleCosts(costData, c.discount, nil, c.byNode)
+		if len(idle) != len(c.cpuIdle) {
+			t.Errorf("%s: got %d idle groups, expected %d", c.name, len(idle), len(c.cpuIdle))
+		}
+		for key, expected := range c.cpuIdle {
+			ic, ok := idle[key]
+			if !ok {
+				t.Errorf("%s: missing idle for %s", c.name, key)
+				continue
+			}
+			if cpu := totalVector(ic.CPUCostVector); !approxEqual(cpu, expected) {
+				t.Errorf("%s: CPU idle of %s is %f, expected %f", c.name, key, cpu, expected)
+			}
+			if ram := totalVector(ic.RAMCostVector); !approxEqual(ram, c.ramIdle[key]) {
+				t.Errorf("%s: RAM idle of %s is %f, expected %f", c.name, key, ram, c.ramIdle[key])
+			}
+		}
+	}
+}
+
+func TestComputeIdleCostsOverAllocated(t *testing.T) {
+	node := testNode(2, 2)
+	costData := map[string]*CostData{
+		"a": testContainer("cluster-one", node, "node-a", "ns1", 3, 1),
+	}
+	idle := computeIdleCosts(costData, 0, nil, false)
+	if cpu := totalVector(idle["cluster-one"].CPUCostVector); cpu != 0 {
+		t.Errorf("CPU idle of an over-allocated node is %f, expected 0", cpu)
+	}
+}
+
+func TestShareIdleCoefficients(t *testing.T) {
+	cases := []struct {
+		name         string
+		idle         []*Vector
+		allocated    []*Vector
+		coefficients map[float64]float64
+		remaining    float64
+	}{
+		{
+			name:         "proportional",
+			idle:         testVectors(2, 3),
+			allocated:    testVectors(4, 6),
+			coefficients: map[float64]float64{3600: 0.5, 7200: 0.5},
+			remaining:    0,
+		},
+		{
+			name:         "nothing allocated",
+			idle:         testVectors(2, 3),
+			allocated:    testVectors(0, 6),
+			coefficients: map[float64]float64{7200: 0.5},
+			remaining:    2,
+		},
+		{
+			name:         "zero idle",
+			idle:         testVectors(0, 0),
+			allocated:    testVectors(4, 6),
+			coefficients: map[float64]float64{3600: 0, 7200: 0},
+			remaining:    0,
+		},
+		{
+			name:         "zero total",
+			idle:         testVectors(0, 0),
+			allocated:    testVectors(0, 0),
+			coefficients: map[float64]float64{},
+			remaining:    0,
+		},
+	}
+	for _, c := range cases {
+		coefficients := shareIdleCoefficients(c.idle, c.allocated)
+		if len(coefficients) != len(c.coefficients) {
+			t.Errorf("%s: got coefficients %v, expected %v", c.name, coefficients, c.coefficients)
+		}
+		for ts, expected := range c.coefficients {
+			if !approxEqual(coefficients[ts], expected) {
+				t.Errorf("%s: coefficient at %f is %f, expected %f", c.name, ts, coefficients[ts], expected)
+			}
+		}
+		if remaining := totalVector(c.idle); !approxEqual(remaining, c.remaining) {
+			t.Errorf("%s: %f idle left unshared, expected %f", c.name, remaining, c.remaining)
+		}
+	}
+}
+
+func TestAggregateCostModelShareIdle(t *testing.T) {
+	node := testNode(4, 4)
+	costData := map[string]*CostData{
+		"a": testContainer("cluster-one", node, "node-a", "ns1", 1, 1),
+		"b": testContainer("cluster-one", node, "node-a", "ns2", 3, 1),
+	}
+	agg := AggregateCostModel(costData, "namespace", "", &AggregationOptions{ShareIdle: true})
+	if _, ok := agg[idleAggregationKey("cluster-one", "")]; ok {
+		t.Errorf("Idle was fully shared, expected no idle aggregation")
+	}
+	if !approxEqual(agg["ns1"].CPUCost, 1) || !approxEqual(agg["ns2"].CPUCost, 3) {
+		t.Errorf("CPU costs are %f and %f, expected 1 and 3 as the node has no idle CPU", agg["ns1"].CPUCost, agg["ns2"].CPUCost)
+	}
+	if !approxEqual(agg["ns1"].RAMCost, 2) || !approxEqual(agg["ns2"].RAMCost, 2) {
+		t.Errorf("RAM costs are %f and %f, expected 2 and 2", agg["ns1"].RAMCost, agg["ns2"].RAMCost)
+	}
+
+	// With nothing allocated on the node, idle cannot be shared and is reported on its own.
+	costData = map[string]*CostData{
+		"a": testContainer("cluster-one", node, "node-a", "ns1", 0, 0),
+	}
+	agg = AggregateCostModel(costData, "namespace", "", &AggregationOptions{ShareIdle: true})
+	idle, ok := agg[idleAggregationKey("cluster-one", "")]
+	if !ok {
+		t.Fatalf("Expected an idle aggregation when nothing is allocated")
+	}
+	if !approxEqual(idle.TotalCost, 8) {
+		t.Errorf("Idle cost is %f, expected 8", idle.TotalCost)
+	}
+	if agg["ns1"].TotalCost != 0 {
+		t.Errorf("Cost of ns1 is %f, expected 0", agg["ns1"].TotalCost)
+	}
+}
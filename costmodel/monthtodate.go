@@ -0,0 +1,110 @@
+package costmodel
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	costAnalyzerCloud "github.com/kubecost/cost-model/cloud"
+	prometheusClient "github.com/prometheus/client_golang/api"
+)
+
+// MonthToDate is the cost accumulated since the first of the month, and the cost of the whole month projected from
+// the recent run-rate.
+type MonthToDate struct {
+	MonthStart    string  `json:"monthStart"`
+	MonthEnd      string  `json:"monthEnd"`
+	HoursElapsed  float64 `json:"hoursElapsed"`
+	HoursInMonth  float64 `json:"hoursInMonth"`
+	Cost          float64 `json:"monthToDateCost"`
+	HourlyRunRate float64 `json:"hourlyRunRate"`
+	ProjectedCost float64 `json:"projectedMonthlyCost"`
+}
+
+// MonthToDateCosts holds the month-to-date cost of the cluster and, optionally, of each aggregation.
+type MonthToDateCosts struct {
+	Cluster      *MonthToDate            `json:"cluster"`
+	Aggregations map[string]*MonthToDate `json:"aggregations,omitempty"`
+}
+
+// MonthBounds returns the start of the month containing now and the start of the next month, in the given location.
+func MonthBounds(now time.Time, loc *time.Location) (time.Time, time.Time) {
+	now = now.In(loc)
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// ClusterMonthToDate accumulates the hourly cost of all nodes and persistent volumes since the start of the month.
+func ClusterMonthToDate(cli prometheusClient.Client, cloud costAnalyzerCloud.Provider, now time.Time, loc *time.Location, runRate time.Duration) (*MonthToDate, error) {
+	start, end := MonthBounds(now, loc)
+	layout := "2006-01-02T15:04:05.000Z"
+	totals, err := ClusterCostsOverTime(cli, cloud, start.UTC().Format(layout), now.UTC().Format(layout), "1h", "")
+	if err != nil {
+		return nil, err
+	}
+	hourly := make([]*Vector, 0, len(totals.TotalCost))
+	for _, point := range totals.TotalCost {
+		timestamp, err := strconv.ParseFloat(point[0], 64)
+		if err != nil {
+			return nil, err
+		}
+		monthly, err := strconv.ParseFloat(point[1], 64)
+		if err != nil || math.IsNaN(monthly) {
+			continue
+		}
+		hourly = append(hourly, &Vector{
+			Timestamp: timestamp,
+			Value:     monthly / hoursPerMonth,
+		})
+	}
+	return newMonthToDate(hourly, start, end, now, runRate), nil
+}
+
+// AggregationsMonthToDate computes the month-to-date cost of aggregations built from hourly cost data starting at
+// the beginning of the month.
+func AggregationsMonthToDate(aggregations map[string]*Aggregation, now time.Time, loc *time.Location, runRate time.Duration) map[string]*MonthToDate {
+	start, end := MonthBounds(now, loc)
+	result := make(map[string]*MonthToDate, len(aggregations))
+	for key, agg := range aggregations {
+		hourly := addVectors(addVectors(agg.CPUCostVector, agg.RAMCostVector), addVectors(agg.GPUCostVector, agg.PVCostVector))
+		hourly = addVectors(hourly, agg.NetworkCostVector)
+		result[key] = newMonthToDate(hourly, start, end, now, runRate)
+	}
+	return result
+}
+
+// newMonthToDate sums hourly costs into the month-to-date cost, and projects the rest of the month at the average
+// hourly cost of the hours in the run-rate window. Each hourly cost covers the hour before its timestamp, so the cost
+// at the start of the month belongs to the month before, and the hours after the last cost up to now are projected.
+func newMonthToDate(hourly []*Vector, start time.Time, end time.Time, now time.Time, runRate time.Duration) *MonthToDate {
+	mtd := &MonthToDate{
+		MonthStart:   start.Format(time.RFC3339),
+		MonthEnd:     end.Format(time.RFC3339),
+		HoursElapsed: now.Sub(start).Hours(),
+		HoursInMonth: end.Sub(start).Hours(),
+	}
+	runRateStart := float64(now.Add(-runRate).Unix())
+	runRateCost := 0.0
+	runRateHours := 0
+	last := 0.0
+	for _, v := range hourly {
+		if v.Timestamp <= float64(start.Unix()) || v.Timestamp > float64(now.Unix()) {
+			continue
+		}
+		mtd.Cost += v.Value
+		last = math.Max(last, v.Timestamp)
+		if v.Timestamp > runRateStart {
+			runRateCost += v.Value
+			runRateHours++
+		}
+	}
+	if runRateHours > 0 {
+		mtd.HourlyRunRate = runRateCost / float64(runRateHours)
+	}
+	projectedHours := end.Sub(now).Hours()
+	if last > 0 {
+		projectedHours = float64(end.Unix())/3600 - last/3600
+	}
+	mtd.ProjectedCost = mtd.Cost + mtd.HourlyRunRate*projectedHours
+	return mtd
+}
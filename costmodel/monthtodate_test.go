@@ -0,0 +1,88 @@
+package costmodel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthBounds(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	cases := []struct {
+		now   time.Time
+		loc   *time.Location
+		start time.Time
+		end   time.Time
+	}{
+		{time.Date(2019, 9, 10, 12, 0, 0, 0, time.UTC), time.UTC, time.Date(2019, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2019, 12, 31, 23, 0, 0, 0, time.UTC), time.UTC, time.Date(2019, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Already October in India.
+		{time.Date(2019, 9, 30, 19, 0, 0, 0, time.UTC), ist, time.Date(2019, 10, 1, 0, 0, 0, 0, ist), time.Date(2019, 11, 1, 0, 0, 0, 0, ist)},
+	}
+	for _, c := range cases {
+		start, end := MonthBounds(c.now, c.loc)
+		if !start.Equal(c.start) || !end.Equal(c.end) {
+			t.Errorf("Month of %s in %s is %s to %s, expected %s to %s", c.now, c.loc, start, end, c.start, c.end)
+		}
+	}
+}
+
+func TestNewMonthToDate(t *testing.T) {
+	start := time.Date(2019, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	// The hours ending at midnight, the month boundary, then at 1am, 2am and 3am, costing 100, 1, 2 and 3.
+	hourly := []*Vector{
+		{Timestamp: float64(start.Unix()), Value: 100},
+		{Timestamp: float64(start.Add(time.Hour).Unix()), Value: 1},
+		{Timestamp: float64(start.Add(2 * time.Hour).Unix()), Value: 2},
+		{Timestamp: float64(start.Add(3 * time.Hour).Unix()), Value: 3},
+	}
+
+	cases := []struct {
+		name      string
+		now       time.Time
+		runRate   time.Duration
+		cost      float64
+		runRateH  float64
+		projected float64
+	}{
+		// The hour ending at the start of the month belongs to August.
+		{"on the hour", start.Add(3 * time.Hour), 2 * time.Hour, 6, 2.5, 6 + 2.5*717},
+		// The half hour since the last hourly cost is projected at the run-rate.
+		{"mid-hour", start.Add(2*time.Hour + 30*time.Minute), 24 * time.Hour, 3, 1.5, 3 + 1.5*718},
+		// Within the first hour, nothing has been spent in September yet.
+		{"partial first hour", start.Add(30 * time.Minute), 24 * time.Hour, 0, 0, 0},
+	}
+	for _, c := range cases {
+		mtd := newMonthToDate(hourly, start, end, c.now, c.runRate)
+		if !approxEqual(mtd.Cost, c.cost) {
+			t.Errorf("%s: month-to-date cost is %f, expected %f", c.name, mtd.Cost, c.cost)
+		}
+		if !approxEqual(mtd.HourlyRunRate, c.runRateH) {
+			t.Errorf("%s: hourly run-rate is %f, expected %f", c.name, mtd.HourlyRunRate, c.runRateH)
+		}
+		if !approxEqual(mtd.ProjectedCost, c.projected) {
+			t.Errorf("%s: projected cost is %f, expected %f", c.name, mtd.ProjectedCost, c.projected)
+		}
+		if !approxEqual(mtd.HoursElapsed, c.now.Sub(start).Hours()) || mtd.HoursInMonth != 720 {
+			t.Errorf("%s: %f of %f hours elapsed, expected %f of 720", c.name, mtd.HoursElapsed, mtd.HoursInMonth, c.now.Sub(start).Hours())
+		}
+	}
+}
+
+func TestAggregationsMonthToDate(t *testing.T) {
+	start := time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(48 * time.Hour)
+	aggregations := map[string]*Aggregation{
+		"ns1": {
+			CPUCostVector:     hourlyCosts(start, now, func(h int) float64 { return 1 }),
+			RAMCostVector:     hourlyCosts(start, now, func(h int) float64 { return 0.5 }),
+			PVCostVector:      hourlyCosts(start, now, func(h int) float64 { return 0.25 }),
+			NetworkCostVector: hourlyCosts(start, now, func(h int) float64 { return 0.25 }),
+		},
+	}
+	mtd := AggregationsMonthToDate(aggregations, now, time.UTC, 24*time.Hour)["ns1"]
+	// February 2019 has 672 hours, 624 of them left.
+	if mtd == nil || !approxEqual(mtd.Cost, 96) || !approxEqual(mtd.HourlyRunRate, 2) || !approxEqual(mtd.ProjectedCost, 96+2*624) {
+		t.Errorf("Month to date is %+v, expected 96 spent and 1344 projected", mtd)
+	}
+}
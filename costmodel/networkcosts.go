@@ -96,21 +96,20 @@ func GetNetworkUsageData(zr interface{}, rr interface{}, ir interface{}, isRange
 	return usageData, nil
 }
 
-// GetNetworkCost computes the actual cost for NetworkUsageData based on data provided by the Provider.
-func GetNetworkCost(usage *NetworkUsageData, cloud costAnalyzerCloud.Provider) ([]*Vector, error) {
-	var results []*Vector
+// NetworkCostData contains the egress cost vectors of a pod, split by the zone, region and internet network tiers.
+type NetworkCostData struct {
+	ZoneCost     []*Vector `json:"zone,omitempty"`
+	RegionCost   []*Vector `json:"region,omitempty"`
+	InternetCost []*Vector `json:"internet,omitempty"`
+}
 
-	pricing, err := cloud.NetworkPricing()
-	if err != nil {
-		return nil, err
-	}
-	zoneCost := pricing.ZoneNetworkEgressCost
-	regionCost := pricing.RegionNetworkEgressCost
-	internetCost := pricing.InternetNetworkEgressCost
+// Total combines the tiers into a single cost vector.
+func (n *NetworkCostData) Total() []*Vector {
+	var results []*Vector
 
-	zlen := len(usage.NetworkZoneEgress)
-	rlen := len(usage.NetworkRegionEgress)
-	ilen := len(usage.NetworkInternetEgress)
+	zlen := len(n.ZoneCost)
+	rlen := len(n.RegionCost)
+	ilen := len(n.InternetCost)
 
 	l := max(zlen, rlen, ilen)
 	for i := 0; i < l; i++ {
@@ -118,18 +117,18 @@ func GetNetworkCost(usage *NetworkUsageData, cloud costAnalyzerCloud.Provider) (
 		var timestamp float64
 
 		if i < zlen {
-			cost += usage.NetworkZoneEgress[i].Value * zoneCost
-			timestamp = usage.NetworkZoneEgress[i].Timestamp
+			cost += n.ZoneCost[i].Value
+			timestamp = n.ZoneCost[i].Timestamp
 		}
 
 		if i < rlen {
-			cost += usage.NetworkRegionEgress[i].Value * regionCost
-			timestamp = usage.NetworkRegionEgress[i].Timestamp
+			cost += n.RegionCost[i].Value
+			timestamp = n.RegionCost[i].Timestamp
 		}
 
 		if i < ilen {
-			cost += usage.NetworkInternetEgress[i].Value * internetCost
-			timestamp = usage.NetworkInternetEgress[i].Timestamp
+			cost += n.InternetCost[i].Value
+			timestamp = n.InternetCost[i].Timestamp
 		}
 
 		results = append(results, &Vector{
@@ -138,7 +137,41 @@ func GetNetworkCost(usage *NetworkUsageData, cloud costAnalyzerCloud.Provider) (
 		})
 	}
 
-	return results, nil
+	return results
+}
+
+// GetNetworkCost computes the actual cost for NetworkUsageData based on data provided by the Provider.
+func GetNetworkCost(usage *NetworkUsageData, cloud costAnalyzerCloud.Provider) ([]*Vector, error) {
+	tiers, err := GetNetworkTierCosts(usage, cloud)
+	if err != nil {
+		return nil, err
+	}
+	return tiers.Total(), nil
+}
+
+// GetNetworkTierCosts computes the cost of each network tier for NetworkUsageData based on data provided by the Provider.
+func GetNetworkTierCosts(usage *NetworkUsageData, cloud costAnalyzerCloud.Provider) (*NetworkCostData, error) {
+	pricing, err := cloud.NetworkPricing()
+	if err != nil {
+		return nil, err
+	}
+
+	return &NetworkCostData{
+		ZoneCost:     priceNetworkUsage(usage.NetworkZoneEgress, pricing.ZoneNetworkEgressCost),
+		RegionCost:   priceNetworkUsage(usage.NetworkRegionEgress, pricing.RegionNetworkEgressCost),
+		InternetCost: priceNetworkUsage(usage.NetworkInternetEgress, pricing.InternetNetworkEgressCost),
+	}, nil
+}
+
+func priceNetworkUsage(usage []*Vector, costPerGB float64) []*Vector {
+	costs := make([]*Vector, 0, len(usage))
+	for _, v := range usage {
+		costs = append(costs, &Vector{
+			Timestamp: v.Timestamp,
+			Value:     v.Value * costPerGB,
+		})
+	}
+	return costs
 }
 
 func getNetworkUsageVector(qr interface{}) (map[string]*NetworkUsageVector, error) {
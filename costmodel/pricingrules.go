@@ -0,0 +1,60 @@
+package costmodel
+
+import (
+	"strconv"
+	"strings"
+
+	costAnalyzerCloud "github.com/kubecost/cost-model/cloud"
+	"k8s.io/klog"
+)
+
+// PricingRules scales the CPU, RAM and GPU cost of containers by the QoS class and PriorityClass of their pod, for
+// instance to charge preemptible batch less than guaranteed services. Storage is always charged in full.
+type PricingRules struct {
+	QoSClasses      map[string]float64
+	PriorityClasses map[string]float64
+}
+
+// NewPricingRules reads the multipliers from the pricing configuration, where each is a comma separated list of
+// "name=multiplier" pairs such as "BestEffort=0.5,Burstable=0.9".
+func NewPricingRules(cfg *costAnalyzerCloud.CustomPricing) *PricingRules {
+	if cfg == nil {
+		return nil
+	}
+	return &PricingRules{
+		QoSClasses:      parsePriceMultipliers(cfg.QoSClassPriceMultipliers),
+		PriorityClasses: parsePriceMultipliers(cfg.PriorityClassPriceMultipliers),
+	}
+}
+
+func parsePriceMultipliers(multipliers string) map[string]float64 {
+	result := make(map[string]float64)
+	for _, m := range strings.Split(multipliers, ",") {
+		kv := strings.SplitN(m, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil || value < 0 {
+			klog.V(1).Infof("Ignoring invalid price multiplier %s", m)
+			continue
+		}
+		result[strings.TrimSpace(kv[0])] = value
+	}
+	return result
+}
+
+// multiplier returns the product of the multipliers matching the container's pod, or 1 if none do.
+func (pr *PricingRules) multiplier(costDatum *CostData) float64 {
+	if pr == nil {
+		return 1.0
+	}
+	m := 1.0
+	if v, ok := pr.QoSClasses[costDatum.QoSClass]; ok {
+		m *= v
+	}
+	if v, ok := pr.PriorityClasses[costDatum.PriorityClass]; ok {
+		m *= v
+	}
+	return m
+}
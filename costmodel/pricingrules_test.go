@@ -0,0 +1,66 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestParsePriceMultipliers(t *testing.T) {
+	cases := []struct {
+		multipliers string
+		expected    map[string]float64
+	}{
+		{"", map[string]float64{}},
+		{"BestEffort=0.5,Burstable=0.9", map[string]float64{"BestEffort": 0.5, "Burstable": 0.9}},
+		{" BestEffort = 0.5 , Guaranteed=1.2", map[string]float64{"BestEffort": 0.5, "Guaranteed": 1.2}},
+		{"BestEffort=half,Burstable=-1,=2,Guaranteed,batch=0", map[string]float64{"batch": 0}},
+	}
+	for _, c := range cases {
+		m := parsePriceMultipliers(c.multipliers)
+		if len(m) != len(c.expected) {
+			t.Errorf("%q parsed as %v, expected %v", c.multipliers, m, c.expected)
+			continue
+		}
+		for k, v := range c.expected {
+			if value, ok := m[k]; !ok || value != v {
+				t.Errorf("%q parsed as %v, expected %v", c.multipliers, m, c.expected)
+			}
+		}
+	}
+}
+
+func TestAggregateCostModelPricingRulesIdle(t *testing.T) {
+	node := testNode(4, 4)
+	costData := func() map[string]*CostData {
+		batch := testContainer("cluster-one", node, "node-a", "ns1", 2, 2)
+		batch.QoSClass = "BestEffort"
+		return map[string]*CostData{
+			"batch":   batch,
+			"service": testContainer("cluster-one", node, "node-a", "ns2", 1, 1),
+		}
+	}
+	rules := &PricingRules{
+		QoSClasses: map[string]float64{"BestEffort": 0.5},
+	}
+
+	// The half of its allocation the batch container is not charged for is not idle.
+	agg := AggregateCostModel(costData(), "namespace", "", &AggregationOptions{IncludeIdle: true, Pricing: rules})
+	idle, ok := agg[idleAggregationKey("cluster-one", "")]
+	if !ok {
+		t.Fatalf("Missing idle aggregation")
+	}
+	if !approxEqual(idle.TotalCost, 2) {
+		t.Errorf("Idle cost is %f, expected 2", idle.TotalCost)
+	}
+	if !approxEqual(agg["ns1"].TotalCost, 2) || !approxEqual(agg["ns2"].TotalCost, 2) {
+		t.Errorf("Costs are %f and %f, expected 2 and 2", agg["ns1"].TotalCost, agg["ns2"].TotalCost)
+	}
+
+	// Shared idle is split in proportion to the charged costs, and does not give the batch container's discount back.
+	agg = AggregateCostModel(costData(), "namespace", "", &AggregationOptions{ShareIdle: true, Pricing: rules})
+	if _, ok := agg[idleAggregationKey("cluster-one", "")]; ok {
+		t.Errorf("Idle was fully shared, expected no idle aggregation")
+	}
+	if !approxEqual(agg["ns1"].TotalCost, 3) || !approxEqual(agg["ns2"].TotalCost, 3) {
+		t.Errorf("Costs are %f and %f, expected 3 and 3", agg["ns1"].TotalCost, agg["ns2"].TotalCost)
+	}
+}
@@ -0,0 +1,313 @@
+package costmodel
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	costAnalyzerCloud "github.com/kubecost/cost-model/cloud"
+	prometheusClient "github.com/prometheus/client_golang/api"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	queryCPUUsagePercentileStr = `max(
+		label_replace(
+			quantile_over_time(%f,
+				rate(container_cpu_usage_seconds_total{container_name!="",container_name!="POD",instance!=""}[5m])[%s:5m]
+			), "node", "$1", "instance", "(.+)"
+		)
+	) by (namespace,container_name,pod_name,node)`
+	queryRAMUsagePercentileStr = `max(
+		label_replace(
+			quantile_over_time(%f,
+				container_memory_working_set_bytes{container_name!="",container_name!="POD",instance!=""}[%s]
+			), "node", "$1", "instance", "(.+)"
+		)
+	) by (namespace,container_name,pod_name,node)`
+
+	hoursPerMonth = 730
+
+	minRecommendedCPU      = 0.01             // 10m
+	minRecommendedRAMBytes = 10 * 1024 * 1024 // 10Mi
+)
+
+// RequestRecommendation is a recommended CPU and RAM request for one container of a controller, based on a percentile
+// of its historical usage across all of the controller's running pods.
+type RequestRecommendation struct {
+	Namespace             string  `json:"namespace"`
+	ControllerKind        string  `json:"controllerKind"`
+	ControllerName        string  `json:"controllerName"`
+	ContainerName         string  `json:"containerName"`
+	Pods                  int     `json:"pods"`
+	CurrentCPURequest     float64 `json:"currentCPURequest"`
+	CurrentRAMRequest     float64 `json:"currentRAMRequestBytes"`
+	RecommendedCPURequest float64 `json:"recommendedCPURequest"`
+	RecommendedRAMRequest float64 `json:"recommendedRAMRequestBytes"`
+	MonthlySavings        float64 `json:"monthlySavings"`
+	monthlyCurrentCPUCost float64
+	monthlyCurrentRAMCost float64
+	podPrices             []podPrice
+	// hasCPUUsage and hasRAMUsage record whether any pod of the controller had usage history for the resource.
+	// Resources without any keep their current request.
+	hasCPUUsage bool
+	hasRAMUsage bool
+}
+
+// podPrice is the monthly price of a CPU core and of a byte of RAM on the node a pod runs on.
+type podPrice struct {
+	CPU float64
+	RAM float64
+}
+
+// ControllerPatch is a strategic merge patch applying the recommended requests to every container of a controller.
+// The patch is a JSON object, or a string when YAML output was requested.
+type ControllerPatch struct {
+	Namespace      string      `json:"namespace"`
+	ControllerKind string      `json:"controllerKind"`
+	ControllerName string      `json:"controllerName"`
+	Patch          interface{} `json:"patch"`
+}
+
+// RequestRecommendations is the result of ComputeRequestRecommendations.
+type RequestRecommendations struct {
+	Recommendations []*RequestRecommendation `json:"recommendations"`
+	Patches         []*ControllerPatch       `json:"patches,omitempty"`
+}
+
+// ComputeRequestRecommendations recommends CPU and RAM requests for the containers of every running pod, using the
+// given percentiles of their usage over window. Savings are projected over a month at the price of each pod's node.
+// patchFormat may be "json" or "yaml" to include patches, or "" to omit them.
+func (cm *CostModel) ComputeRequestRecommendations(cli prometheusClient.Client, cloud costAnalyzerCloud.Provider, window string, cpuPercentile float64, ramPercentile float64, discount float64, patchFormat string) (*RequestRecommendations, error) {
+	if patchFormat != "" && patchFormat != "json" && patchFormat != "yaml" {
+		return nil, fmt.Errorf("Invalid patch format '%s', expected json or yaml", patchFormat)
+	}
+	resultCPU, err := Query(cli, fmt.Sprintf(queryCPUUsagePercentileStr, cpuPercentile, window))
+	if err != nil {
+		return nil, fmt.Errorf("Error querying prometheus: %s", err.Error())
+	}
+	resultRAM, err := Query(cli, fmt.Sprintf(queryRAMUsagePercentileStr, ramPercentile, window))
+	if err != nil {
+		return nil, fmt.Errorf("Error querying prometheus: %s", err.Error())
+	}
+	cpuUsage, err := GetContainerMetricVector(resultCPU, false, 0)
+	if err != nil {
+		return nil, err
+	}
+	ramUsage, err := GetContainerMetricVector(resultRAM, false, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := getNodeCost(cm.Cache, cloud)
+	if err != nil {
+		return nil, err
+	}
+	podList := cm.Cache.GetAllPods()
+	podDeploymentsMapping, err := getPodDeployments(cm.Cache, podList)
+	if err != nil {
+		return nil, err
+	}
+	jobCronJobsMapping, err := getJobCronJobs(cm.Cache)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := make(map[string]*RequestRecommendation)
+	for _, pod := range podList {
+		if pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		kind, name := getControllerOfPod(*pod, podDeploymentsMapping, jobCronJobsMapping)
+		var cpuCost, ramCost float64
+		if node, ok := nodes[pod.Spec.NodeName]; ok && node != nil {
+			cpuCost, _ = strconv.ParseFloat(node.VCPUCost, 64)
+			ramCost, _ = strconv.ParseFloat(node.RAMCost, 64)
+		}
+		for _, container := range pod.Spec.Containers {
+			key := newContainerMetricFromValues(pod.Namespace, pod.Name, container.Name, pod.Spec.NodeName).Key()
+			cpuV, okCPU := cpuUsage[key]
+			ramV, okRAM := ramUsage[key]
+			if !okCPU && !okRAM {
+				klog.V(4).Infof("No usage history for %s", key)
+				continue
+			}
+
+			recKey := pod.Namespace + "," + kind + "," + name + "," + container.Name
+			rec, ok := recommendations[recKey]
+			if !ok {
+				rec = &RequestRecommendation{
+					Namespace:      pod.Namespace,
+					ControllerKind: kind,
+					ControllerName: name,
+					ContainerName:  container.Name,
+				}
+				recommendations[recKey] = rec
+			}
+			rec.Pods++
+			cpuReq := float64(container.Resources.Requests.Cpu().MilliValue()) / 1000
+			ramReq := float64(container.Resources.Requests.Memory().Value())
+			rec.CurrentCPURequest = math.Max(rec.CurrentCPURequest, cpuReq)
+			rec.CurrentRAMRequest = math.Max(rec.CurrentRAMRequest, ramReq)
+			if okCPU && len(cpuV) > 0 {
+				rec.hasCPUUsage = true
+				rec.RecommendedCPURequest = math.Max(rec.RecommendedCPURequest, cpuV[0].Value)
+			}
+			if okRAM && len(ramV) > 0 {
+				rec.hasRAMUsage = true
+				rec.RecommendedRAMRequest = math.Max(rec.RecommendedRAMRequest, ramV[0].Value)
+			}
+			price := podPrice{
+				CPU: cpuCost * (1 - discount) * hoursPerMonth,
+				RAM: ramCost * (1 - discount) * hoursPerMonth / 1024 / 1024 / 1024,
+			}
+			rec.podPrices = append(rec.podPrices, price)
+			rec.monthlyCurrentCPUCost += cpuReq * price.CPU
+			rec.monthlyCurrentRAMCost += ramReq * price.RAM
+		}
+	}
+
+	result := &RequestRecommendations{
+		Recommendations: make([]*RequestRecommendation, 0, len(recommendations)),
+	}
+	for _, rec := range recommendations {
+		rec.computeSavings()
+		result.Recommendations = append(result.Recommendations, rec)
+	}
+	sort.Slice(result.Recommendations, func(i, j int) bool {
+		return result.Recommendations[i].MonthlySavings > result.Recommendations[j].MonthlySavings
+	})
+
+	if patchFormat != "" {
+		result.Patches, err = getControllerPatches(result.Recommendations, patchFormat)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// computeSavings sets the recommended requests of the resources with usage history, no lower than the minimum
+// requests, and the monthly savings of applying them to every pod of the controller, each priced on its own node.
+// Resources without usage history keep their current request and save nothing.
+func (rec *RequestRecommendation) computeSavings() {
+	rec.MonthlySavings = 0
+	if rec.hasCPUUsage {
+		rec.RecommendedCPURequest = math.Max(rec.RecommendedCPURequest, minRecommendedCPU)
+		monthlyRecommendedCost := 0.0
+		for _, price := range rec.podPrices {
+			monthlyRecommendedCost += rec.RecommendedCPURequest * price.CPU
+		}
+		rec.MonthlySavings += rec.monthlyCurrentCPUCost - monthlyRecommendedCost
+	} else {
+		rec.RecommendedCPURequest = rec.CurrentCPURequest
+	}
+	if rec.hasRAMUsage {
+		rec.RecommendedRAMRequest = math.Max(rec.RecommendedRAMRequest, minRecommendedRAMBytes)
+		monthlyRecommendedCost := 0.0
+		for _, price := range rec.podPrices {
+			monthlyRecommendedCost += rec.RecommendedRAMRequest * price.RAM
+		}
+		rec.MonthlySavings += rec.monthlyCurrentRAMCost - monthlyRecommendedCost
+	} else {
+		rec.RecommendedRAMRequest = rec.CurrentRAMRequest
+	}
+}
+
+// getControllerOfPod returns the kind and name of the controller owning a pod, or "pod" and the pod's name if it has none.
+func getControllerOfPod(pod v1.Pod, podDeploymentsMapping map[string]map[string][]string, jobCronJobsMapping map[string]map[string]string) (string, string) {
+	if ds, ok := podDeploymentsMapping[pod.Namespace][pod.Name]; ok && len(ds) > 0 {
+		return "deployment", ds[0]
+	}
+	if ss := getStatefulSetsOfPod(pod); len(ss) > 0 {
+		return "statefulset", ss[0]
+	}
+	if ds := getDaemonsetsOfPod(pod); len(ds) > 0 {
+		return "daemonset", ds[0]
+	}
+	if cjs := getCronJobsOfPod(pod, jobCronJobsMapping); len(cjs) > 0 {
+		return "cronjob", cjs[0]
+	}
+	if js := getJobsOfPod(pod); len(js) > 0 {
+		return "job", js[0]
+	}
+	return "pod", pod.Name
+}
+
+// getControllerPatches builds one patch per controller. Pods and jobs are skipped, as their pod spec cannot be
+// changed once created. Only the requests of resources with usage history are patched.
+func getControllerPatches(recommendations []*RequestRecommendation, patchFormat string) ([]*ControllerPatch, error) {
+	containers := make(map[string][]interface{})
+	patches := make(map[string]*ControllerPatch)
+	var keys []string
+	for _, rec := range recommendations {
+		if rec.ControllerKind == "pod" || rec.ControllerKind == "job" {
+			continue
+		}
+		key := rec.Namespace + "," + rec.ControllerKind + "," + rec.ControllerName
+		if _, ok := patches[key]; !ok {
+			patches[key] = &ControllerPatch{
+				Namespace:      rec.Namespace,
+				ControllerKind: rec.ControllerKind,
+				ControllerName: rec.ControllerName,
+			}
+			keys = append(keys, key)
+		}
+		requests := make(map[string]string)
+		if rec.hasCPUUsage {
+			requests["cpu"] = fmt.Sprintf("%dm", int64(math.Ceil(rec.RecommendedCPURequest*1000)))
+		}
+		if rec.hasRAMUsage {
+			requests["memory"] = fmt.Sprintf("%dMi", int64(math.Ceil(rec.RecommendedRAMRequest/1024/1024)))
+		}
+		containers[key] = append(containers[key], map[string]interface{}{
+			"name": rec.ContainerName,
+			"resources": map[string]interface{}{
+				"requests": requests,
+			},
+		})
+	}
+
+	sort.Strings(keys)
+	result := make([]*ControllerPatch, 0, len(keys))
+	for _, key := range keys {
+		p := patches[key]
+		podSpec := map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": containers[key],
+				},
+			},
+		}
+		var patch interface{} = map[string]interface{}{
+			"spec": podSpec,
+		}
+		if p.ControllerKind == "cronjob" {
+			patch = map[string]interface{}{
+				"spec": map[string]interface{}{
+					"jobTemplate": map[string]interface{}{
+						"spec": podSpec,
+					},
+				},
+			}
+		}
+		if patchFormat == "yaml" {
+			j, err := json.Marshal(patch)
+			if err != nil {
+				return nil, err
+			}
+			y, err := yaml.JSONToYAML(j)
+			if err != nil {
+				return nil, err
+			}
+			patch = string(y)
+		}
+		p.Patch = patch
+		result = append(result, p)
+	}
+	return result, nil
+}
@@ -0,0 +1,116 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestComputeSavings(t *testing.T) {
+	prices := []podPrice{{CPU: 10, RAM: 1.0 / gib}, {CPU: 20, RAM: 2.0 / gib}}
+	cases := []struct {
+		name       string
+		rec        *RequestRecommendation
+		cpuRequest float64
+		ramRequest float64
+		savings    float64
+	}{
+		{
+			name: "cpu and ram usage",
+			rec: &RequestRecommendation{
+				CurrentCPURequest:     1,
+				CurrentRAMRequest:     2 * gib,
+				RecommendedCPURequest: 0.5,
+				RecommendedRAMRequest: 1 * gib,
+				hasCPUUsage:           true,
+				hasRAMUsage:           true,
+			},
+			cpuRequest: 0.5,
+			ramRequest: 1 * gib,
+			savings:    0.5*(10+20) + 1*(1+2),
+		},
+		{
+			name: "no ram usage",
+			rec: &RequestRecommendation{
+				CurrentCPURequest:     1,
+				CurrentRAMRequest:     2 * gib,
+				RecommendedCPURequest: 0.5,
+				hasCPUUsage:           true,
+			},
+			cpuRequest: 0.5,
+			ramRequest: 2 * gib,
+			savings:    0.5 * (10 + 20),
+		},
+		{
+			name: "no cpu usage",
+			rec: &RequestRecommendation{
+				CurrentCPURequest:     1,
+				CurrentRAMRequest:     2 * gib,
+				RecommendedRAMRequest: 1 * gib,
+				hasRAMUsage:           true,
+			},
+			cpuRequest: 1,
+			ramRequest: 1 * gib,
+			savings:    1 * (1 + 2),
+		},
+		{
+			name: "usage below the minimum",
+			rec: &RequestRecommendation{
+				CurrentCPURequest:     0.005,
+				CurrentRAMRequest:     2 * gib,
+				RecommendedCPURequest: 0.001,
+				RecommendedRAMRequest: 2 * gib,
+				hasCPUUsage:           true,
+				hasRAMUsage:           true,
+			},
+			cpuRequest: minRecommendedCPU,
+			ramRequest: 2 * gib,
+			savings:    (0.005 - minRecommendedCPU) * (10 + 20),
+		},
+	}
+	for _, c := range cases {
+		rec := c.rec
+		rec.podPrices = prices
+		for _, p := range prices {
+			rec.monthlyCurrentCPUCost += rec.CurrentCPURequest * p.CPU
+			rec.monthlyCurrentRAMCost += rec.CurrentRAMRequest * p.RAM
+		}
+		rec.computeSavings()
+		if !approxEqual(rec.RecommendedCPURequest, c.cpuRequest) {
+			t.Errorf("%s: recommended CPU request is %f, expected %f", c.name, rec.RecommendedCPURequest, c.cpuRequest)
+		}
+		if !approxEqual(rec.RecommendedRAMRequest, c.ramRequest) {
+			t.Errorf("%s: recommended RAM request is %f, expected %f", c.name, rec.RecommendedRAMRequest, c.ramRequest)
+		}
+		if !approxEqual(rec.MonthlySavings, c.savings) {
+			t.Errorf("%s: monthly savings are %f, expected %f", c.name, rec.MonthlySavings, c.savings)
+		}
+	}
+}
+
+func TestGetControllerPatchesWithoutUsage(t *testing.T) {
+	recs := []*RequestRecommendation{
+		{
+			Namespace:             "ns1",
+			ControllerKind:        "deployment",
+			ControllerName:        "web",
+			ContainerName:         "app",
+			RecommendedCPURequest: 0.25,
+			RecommendedRAMRequest: 2 * gib,
+			hasCPUUsage:           true,
+		},
+	}
+	patches, err := getControllerPatches(recs, "json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	if len(patches) != 1 {
+		t.Fatalf("Got %d patches, expected 1", len(patches))
+	}
+	containers := patches[0].Patch.(map[string]interface{})["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+	requests := containers[0].(map[string]interface{})["resources"].(map[string]interface{})["requests"].(map[string]string)
+	if requests["cpu"] != "250m" {
+		t.Errorf("CPU request patched to %s, expected 250m", requests["cpu"])
+	}
+	if _, ok := requests["memory"]; ok {
+		t.Errorf("Memory request patched to %s without usage history", requests["memory"])
+	}
+}
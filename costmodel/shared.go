@@ -0,0 +1,120 @@
+package costmodel
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// SharedSplitEven gives every aggregation an equal part of the shared cost.
+	SharedSplitEven = "even"
+	// SharedSplitWeighted gives every aggregation a part of the shared cost proportional to its own cost.
+	SharedSplitWeighted = "weighted"
+)
+
+// SharedResourceInfo describes the namespaces and labels whose cost is not reported on its own, but redistributed
+// across all other aggregations.
+type SharedResourceInfo struct {
+	Namespaces map[string]bool
+	Labels     map[string]string
+	Split      string
+}
+
+// NewSharedResourceInfo builds a SharedResourceInfo from a list of namespaces and a list of "name=value" label
+// selectors. An empty split defaults to SharedSplitWeighted, any other than SharedSplitEven or SharedSplitWeighted is
+// an error.
+func NewSharedResourceInfo(namespaces []string, labels []string, split string) (*SharedResourceInfo, error) {
+	if split != "" && split != SharedSplitEven && split != SharedSplitWeighted {
+		return nil, fmt.Errorf("Invalid shared split %s, expected %s or %s", split, SharedSplitEven, SharedSplitWeighted)
+	}
+	sr := &SharedResourceInfo{
+		Namespaces: make(map[string]bool),
+		Labels:     make(map[string]string),
+		Split:      split,
+	}
+	if sr.Split == "" {
+		sr.Split = SharedSplitWeighted
+	}
+	for _, ns := range namespaces {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			sr.Namespaces[ns] = true
+		}
+	}
+	for _, l := range labels {
+		kv := strings.SplitN(l, "=", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) != "" {
+			sr.Labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+	}
+	return sr, nil
+}
+
+// IsSharedResource returns true if the container belongs to a shared namespace or matches a shared label.
+func (sr *SharedResourceInfo) IsSharedResource(costDatum *CostData) bool {
+	if sr == nil {
+		return false
+	}
+	if sr.Namespaces[costDatum.Namespace] {
+		return true
+	}
+	for name, value := range sr.Labels {
+		if v, ok := costDatum.Labels[name]; ok && v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// distributeSharedCosts splits the cost vectors of the shared aggregation across the given aggregations, either
+// evenly or weighted by each aggregation's own cost at every timestamp.
+func distributeSharedCosts(targets []*Aggregation, shared *Aggregation, split string) {
+	if len(targets) == 0 {
+		return
+	}
+
+	totals := make([]map[float64]float64, len(targets))
+	sums := make(map[float64]float64)
+	for i, agg := range targets {
+		totals[i] = vectorMap(addVectors(addVectors(agg.CPUCostVector, agg.RAMCostVector), addVectors(agg.GPUCostVector, agg.PVCostVector)))
+		for t, v := range vectorMap(agg.NetworkCostVector) {
+			totals[i][t] += v
+		}
+		for t, v := range totals[i] {
+			sums[t] += v
+		}
+	}
+	weight := func(i int, t float64) float64 {
+		if split == SharedSplitEven || sums[t] == 0 {
+			return 1.0 / float64(len(targets))
+		}
+		return totals[i][t] / sums[t]
+	}
+	share := func(i int, vectors []*Vector) []*Vector {
+		shares := make([]*Vector, 0, len(vectors))
+		for _, v := range vectors {
+			shares = append(shares, &Vector{
+				Timestamp: v.Timestamp,
+				Value:     v.Value * weight(i, v.Timestamp),
+			})
+		}
+		return shares
+	}
+
+	for i, agg := range targets {
+		cpuv := share(i, shared.CPUCostVector)
+		ramv := share(i, shared.RAMCostVector)
+		gpuv := share(i, shared.GPUCostVector)
+		pvv := share(i, shared.PVCostVector)
+		netv := share(i, shared.NetworkCostVector)
+		agg.SharedCost += totalVector(cpuv) + totalVector(ramv) + totalVector(gpuv) + totalVector(pvv) + totalVector(netv)
+
+		agg.CPUCostVector = addVectors(cpuv, agg.CPUCostVector)
+		agg.RAMCostVector = addVectors(ramv, agg.RAMCostVector)
+		agg.GPUCostVector = addVectors(gpuv, agg.GPUCostVector)
+		agg.PVCostVector = addVectors(pvv, agg.PVCostVector)
+		agg.NetworkCostVector = addVectors(netv, agg.NetworkCostVector)
+		agg.NetworkZoneVector = addVectors(share(i, shared.NetworkZoneVector), agg.NetworkZoneVector)
+		agg.NetworkRegionVector = addVectors(share(i, shared.NetworkRegionVector), agg.NetworkRegionVector)
+		agg.NetworkInternetVector = addVectors(share(i, shared.NetworkInternetVector), agg.NetworkInternetVector)
+	}
+}
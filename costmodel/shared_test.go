@@ -0,0 +1,119 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestNewSharedResourceInfo(t *testing.T) {
+	cases := []struct {
+		split    string
+		expected string
+		valid    bool
+	}{
+		{"", SharedSplitWeighted, true},
+		{SharedSplitEven, SharedSplitEven, true},
+		{SharedSplitWeighted, SharedSplitWeighted, true},
+		{"evn", "", false},
+		{"Even", "", false},
+	}
+	for _, c := range cases {
+		sr, err := NewSharedResourceInfo([]string{"kube-system", " "}, []string{"app=shared", "invalid"}, c.split)
+		if !c.valid {
+			if err == nil {
+				t.Errorf("Expected an error for split %q", c.split)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for split %q: %s", c.split, err.Error())
+			continue
+		}
+		if sr.Split != c.expected {
+			t.Errorf("Split %q parsed as %q, expected %q", c.split, sr.Split, c.expected)
+		}
+		if len(sr.Namespaces) != 1 || !sr.Namespaces["kube-system"] {
+			t.Errorf("Unexpected shared namespaces %v", sr.Namespaces)
+		}
+		if len(sr.Labels) != 1 || sr.Labels["app"] != "shared" {
+			t.Errorf("Unexpected shared labels %v", sr.Labels)
+		}
+	}
+}
+
+func TestDistributeSharedCosts(t *testing.T) {
+	cases := []struct {
+		name     string
+		split    string
+		costs    [][]float64 // CPU cost of each target, per timestamp
+		shared   []float64
+		expected []float64 // shared cost of each target
+	}{
+		{
+			name:     "even",
+			split:    SharedSplitEven,
+			costs:    [][]float64{{1, 1}, {3, 3}},
+			shared:   []float64{4, 4},
+			expected: []float64{4, 4},
+		},
+		{
+			name:     "weighted",
+			split:    SharedSplitWeighted,
+			costs:    [][]float64{{1, 1}, {3, 3}},
+			shared:   []float64{4, 4},
+			expected: []float64{2, 6},
+		},
+		{
+			name:     "weighted per timestamp",
+			split:    SharedSplitWeighted,
+			costs:    [][]float64{{1, 0}, {3, 2}},
+			shared:   []float64{4, 4},
+			expected: []float64{1, 7},
+		},
+		{
+			name:     "weighted without cost",
+			split:    SharedSplitWeighted,
+			costs:    [][]float64{{0, 0}, {0, 0}},
+			shared:   []float64{4, 4},
+			expected: []float64{4, 4},
+		},
+	}
+	for _, c := range cases {
+		targets := make([]*Aggregation, 0, len(c.costs))
+		for _, cost := range c.costs {
+			targets = append(targets, &Aggregation{
+				CPUCostVector: testVectors(cost...),
+			})
+		}
+		shared := &Aggregation{
+			CPUCostVector: testVectors(c.shared...),
+			RAMCostVector: testVectors(c.shared...),
+		}
+		distributeSharedCosts(targets, shared, c.split)
+		for i, agg := range targets {
+			// The shared CPU and RAM costs are split alike.
+			if !approxEqual(agg.SharedCost, 2*c.expected[i]) {
+				t.Errorf("%s: shared cost of target %d is %f, expected %f", c.name, i, agg.SharedCost, 2*c.expected[i])
+			}
+			own := 0.0
+			for _, v := range c.costs[i] {
+				own += v
+			}
+			if cpu := totalVector(agg.CPUCostVector); !approxEqual(cpu, own+c.expected[i]) {
+				t.Errorf("%s: CPU cost of target %d is %f, expected %f", c.name, i, cpu, own+c.expected[i])
+			}
+			if ram := totalVector(agg.RAMCostVector); !approxEqual(ram, c.expected[i]) {
+				t.Errorf("%s: RAM cost of target %d is %f, expected %f", c.name, i, ram, c.expected[i])
+			}
+		}
+	}
+}
+
+func TestDistributeSharedCostsNoTargets(t *testing.T) {
+	shared := &Aggregation{
+		CPUCostVector: testVectors(1),
+	}
+	distributeSharedCosts(nil, shared, SharedSplitWeighted)
+	if totalVector(shared.CPUCostVector) != 1 {
+		t.Errorf("Shared cost changed without targets")
+	}
+}
@@ -0,0 +1,84 @@
+package costmodel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	// ResolutionHourly reports one point per hour.
+	ResolutionHourly = "hourly"
+	// ResolutionDaily reports one point per UTC day.
+	ResolutionDaily = "daily"
+)
+
+// CostTimeSeriesPoint is the cost of an aggregation over one step of its time series.
+type CostTimeSeriesPoint struct {
+	Timestamp   float64 `json:"timestamp"`
+	CPUCost     float64 `json:"cpuCost"`
+	RAMCost     float64 `json:"ramCost"`
+	GPUCost     float64 `json:"gpuCost"`
+	PVCost      float64 `json:"pvCost"`
+	NetworkCost float64 `json:"networkCost"`
+	TotalCost   float64 `json:"totalCost"`
+}
+
+// ComputeTimeSeries sets the time series of every aggregation, summing its hourly cost vectors into steps of the given
+// resolution. Each step is keyed by the timestamp it starts at. An hourly vector is timestamped at the end of the hour
+// it covers, so it is summed into the step that hour starts in.
+func ComputeTimeSeries(aggregations map[string]*Aggregation, resolution string) error {
+	var step float64
+	switch resolution {
+	case ResolutionHourly, "":
+		step = 60 * 60
+	case ResolutionDaily:
+		step = 24 * 60 * 60
+	default:
+		return fmt.Errorf("Invalid resolution '%s', expected hourly or daily", resolution)
+	}
+	for _, agg := range aggregations {
+		points := make(map[float64]*CostTimeSeriesPoint)
+		// Vectors without a timestamp are summed into a point that is never reported.
+		missing := &CostTimeSeriesPoint{}
+		point := func(timestamp float64) *CostTimeSeriesPoint {
+			if timestamp == 0 {
+				return missing
+			}
+			t := math.Floor((timestamp-60*60)/step) * step
+			p, ok := points[t]
+			if !ok {
+				p = &CostTimeSeriesPoint{
+					Timestamp: t,
+				}
+				points[t] = p
+			}
+			return p
+		}
+		for _, v := range agg.CPUCostVector {
+			point(v.Timestamp).CPUCost += v.Value
+		}
+		for _, v := range agg.RAMCostVector {
+			point(v.Timestamp).RAMCost += v.Value
+		}
+		for _, v := range agg.GPUCostVector {
+			point(v.Timestamp).GPUCost += v.Value
+		}
+		for _, v := range agg.PVCostVector {
+			point(v.Timestamp).PVCost += v.Value
+		}
+		for _, v := range agg.NetworkCostVector {
+			point(v.Timestamp).NetworkCost += v.Value
+		}
+
+		agg.TimeSeries = make([]*CostTimeSeriesPoint, 0, len(points))
+		for _, p := range points {
+			p.TotalCost = p.CPUCost + p.RAMCost + p.GPUCost + p.PVCost + p.NetworkCost
+			agg.TimeSeries = append(agg.TimeSeries, p)
+		}
+		sort.Slice(agg.TimeSeries, func(i, j int) bool {
+			return agg.TimeSeries[i].Timestamp < agg.TimeSeries[j].Timestamp
+		})
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package costmodel
+
+import (
+	"testing"
+)
+
+func TestComputeTimeSeries(t *testing.T) {
+	day := float64(24 * 60 * 60)
+	// The hours ending at midnight, 1am and 2am.
+	cpu := []*Vector{{Timestamp: day, Value: 1}, {Timestamp: day + 3600, Value: 2}, {Timestamp: day + 7200, Value: 4}, {Timestamp: 0, Value: 8}}
+	ram := []*Vector{{Timestamp: day, Value: 0.5}}
+	network := []*Vector{{Timestamp: day + 3600, Value: 0.25}}
+
+	cases := []struct {
+		resolution string
+		timestamps []float64
+		totals     []float64
+	}{
+		{"", []float64{day - 3600, day, day + 3600}, []float64{1.5, 2.25, 4}},
+		{ResolutionHourly, []float64{day - 3600, day, day + 3600}, []float64{1.5, 2.25, 4}},
+		{ResolutionDaily, []float64{0, day}, []float64{1.5, 6.25}},
+	}
+	for _, c := range cases {
+		aggregations := map[string]*Aggregation{
+			"ns1": {CPUCostVector: cpu, RAMCostVector: ram, NetworkCostVector: network},
+		}
+		if err := ComputeTimeSeries(aggregations, c.resolution); err != nil {
+			t.Errorf("%q: unexpected error: %s", c.resolution, err.Error())
+			continue
+		}
+		series := aggregations["ns1"].TimeSeries
+		if len(series) != len(c.timestamps) {
+			t.Errorf("%q: got %d points, expected %d", c.resolution, len(series), len(c.timestamps))
+			continue
+		}
+		for i, p := range series {
+			if p.Timestamp != c.timestamps[i] || !approxEqual(p.TotalCost, c.totals[i]) {
+				t.Errorf("%q: point %d costs %f at %f, expected %f at %f", c.resolution, i, p.TotalCost, p.Timestamp, c.totals[i], c.timestamps[i])
+			}
+		}
+	}
+
+	// The hour ending at midnight belongs to the day before.
+	aggregations := map[string]*Aggregation{"ns1": {CPUCostVector: cpu, RAMCostVector: ram}}
+	ComputeTimeSeries(aggregations, ResolutionDaily)
+	if p := aggregations["ns1"].TimeSeries[0]; p.CPUCost != 1 || p.RAMCost != 0.5 {
+		t.Errorf("First day costs %f CPU and %f RAM, expected 1 and 0.5", p.CPUCost, p.RAMCost)
+	}
+
+	if err := ComputeTimeSeries(aggregations, "weekly"); err == nil {
+		t.Errorf("Expected an error for an invalid resolution")
+	}
+}
@@ -34,6 +34,7 @@ const (
 	prometheusServerEndpointEnvVar = "PROMETHEUS_SERVER_ENDPOINT"
 	prometheusTroubleshootingEp    = "http://docs.kubecost.com/custom-prom#troubleshoot"
 	remoteEnabled                  = "REMOTE_WRITE_ENABLED"
+	budgetEvaluationInterval       = time.Hour
 )
 
 var (
@@ -60,7 +61,13 @@ type Accesses struct {
 	NetworkInternetEgressRecorder prometheus.Gauge
 	ServiceSelectorRecorder       *prometheus.GaugeVec
 	DeploymentSelectorRecorder    *prometheus.GaugeVec
+	NamespaceHourlyCostRecorder   *prometheus.GaugeVec
+	LabelMonthlyCostRecorder      *prometheus.GaugeVec
+	BudgetUtilizationRecorder     *prometheus.GaugeVec
+	BudgetProjectedUtilRecorder   *prometheus.GaugeVec
 	Model                         *costModel.CostModel
+	Anomalies                     *costModel.AnomalyLog
+	Budgets                       *costModel.BudgetStore
 }
 
 type DataEnvelope struct {
@@ -91,6 +98,27 @@ func wrapData(data interface{}, err error) []byte {
 	return resp
 }
 
+// badRequestError is an error in the parameters of a request, reported with a 400 rather than a 500.
+type badRequestError struct {
+	error
+}
+
+// writeError writes the error in a data envelope, with a 400 status if it is a badRequestError.
+func writeError(w http.ResponseWriter, err error) {
+	if _, ok := err.(badRequestError); !ok {
+		w.Write(wrapData(nil, err))
+		return
+	}
+	klog.V(1).Infof("Bad request: %s", err.Error())
+	resp, _ := json.Marshal(&DataEnvelope{
+		Code:    http.StatusBadRequest,
+		Status:  "error",
+		Message: err.Error(),
+	})
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(resp)
+}
+
 // RefreshPricingData needs to be called when a new node joins the fleet, since we cache the relevant subsets of pricing data to avoid storing the whole thing.
 func (a *Accesses) RefreshPricingData(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	w.Header().Set("Content-Type", "application/json")
@@ -129,6 +157,49 @@ func filterFields(fields string, data map[string]*costModel.CostData) map[string
 	return filteredData
 }
 
+// aggregationOptions reads the query parameters shared by the endpoints that aggregate. Idle is the difference
+// between node capacity and what all containers were allocated, so it cannot be computed for a single namespace.
+// Invalid parameters are returned as a badRequestError.
+func aggregationOptions(r *http.Request, c *costAnalyzerCloud.CustomPricing, discount float64, namespace string) (*costModel.AggregationOptions, error) {
+	opts := &costModel.AggregationOptions{
+		Discount: discount,
+		Pricing:  costModel.NewPricingRules(c),
+	}
+	if namespace == "" {
+		opts.IncludeIdle = r.URL.Query().Get("includeIdle") == "true"
+		opts.IdleByNode = r.URL.Query().Get("idleByNode") == "true"
+		opts.ShareIdle = r.URL.Query().Get("shareIdle") == "true"
+	}
+	opts.DedicatedNodes = r.URL.Query().Get("dedicatedNodes") == "true"
+	if c.DedicatedNodeLabel != "" {
+		opts.TenantLevel = "label:" + c.DedicatedNodeLabel
+	}
+	sharedNamespaces := r.URL.Query().Get("sharedNamespaces")
+	sharedLabels := r.URL.Query().Get("sharedLabels")
+	if sharedNamespaces != "" || sharedLabels != "" {
+		shared, err := costModel.NewSharedResourceInfo(strings.Split(sharedNamespaces, ","), strings.Split(sharedLabels, ","), r.URL.Query().Get("sharedSplit"))
+		if err != nil {
+			return nil, badRequestError{err}
+		}
+		opts.Shared = shared
+	}
+	return opts, nil
+}
+
+// allocationStrategy reads the allocation strategy of a request, or returns nil to keep the model's own. An invalid
+// strategy is returned as a badRequestError.
+func allocationStrategy(r *http.Request) (*costModel.AllocationStrategy, error) {
+	strategy := r.URL.Query().Get("allocation")
+	if strategy == "" {
+		return nil, nil
+	}
+	s, err := costModel.NewAllocationStrategy(strategy, r.URL.Query().Get("allocationWeight"))
+	if err != nil {
+		return nil, badRequestError{err}
+	}
+	return s, nil
+}
+
 func (a *Accesses) CostDataModel(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -144,7 +215,15 @@ func (a *Accesses) CostDataModel(w http.ResponseWriter, r *http.Request, ps http
 		offset = "offset " + offset
 	}
 
+	strategy, err := allocationStrategy(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	data, err := a.Model.ComputeCostData(a.PrometheusClient, a.KubeClientSet, a.Cloud, window, offset, namespace)
+	if strategy != nil {
+		costModel.ApplyAllocationStrategy(data, strategy)
+	}
 	if aggregation != "" {
 		c, err := a.Cloud.GetConfig()
 		if err != nil {
@@ -155,7 +234,19 @@ func (a *Accesses) CostDataModel(w http.ResponseWriter, r *http.Request, ps http
 			w.Write(wrapData(nil, err))
 		}
 
-		agg := costModel.AggregateCostModel(data, discount, aggregation, aggregationSubField)
+		opts, err := aggregationOptions(r, c, discount*0.01, namespace)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if opts.DedicatedNodes && namespace == "" {
+			opts.DedicatedNodeCosts, err = a.Model.ComputeDedicatedNodes(a.PrometheusClient, a.Cloud, window, offset)
+			if err != nil {
+				w.Write(wrapData(nil, err))
+				return
+			}
+		}
+		agg := costModel.AggregateCostModel(data, aggregation, aggregationSubField, opts)
 		w.Write(wrapData(agg, nil))
 	} else {
 		if fields != "" {
@@ -199,6 +290,213 @@ func (a *Accesses) ClusterCostsOverTime(w http.ResponseWriter, r *http.Request,
 	w.Write(wrapData(data, err))
 }
 
+// MonthToDateCosts returns the cost accumulated since the first of the month in the requested timezone, and the
+// cost of the whole month projected from the run-rate of the last runRateWindow, for the cluster and optionally for
+// each aggregation.
+func (a *Accesses) MonthToDateCosts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	timezone := r.URL.Query().Get("timezone")
+	runRateWindow := r.URL.Query().Get("runRateWindow")
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		writeError(w, badRequestError{err})
+		return
+	}
+	runRate := 24 * time.Hour
+	if runRateWindow != "" {
+		runRate, err = parseWindow(runRateWindow)
+		if err != nil {
+			writeError(w, badRequestError{err})
+			return
+		}
+	}
+
+	now := time.Now()
+	cluster, err := costModel.ClusterMonthToDate(a.PrometheusClient, a.Cloud, now, loc, runRate)
+	if err != nil {
+		w.Write(wrapData(nil, err))
+		return
+	}
+	result := &costModel.MonthToDateCosts{
+		Cluster: cluster,
+	}
+
+	// Each hourly step of cost data covers the hour before it, so the first step is an hour into the month.
+	monthStart, _ := costModel.MonthBounds(now, loc)
+	start := monthStart.Add(time.Hour)
+	if r.URL.Query().Get("aggregation") != "" && start.Before(now) {
+		agg, err := a.aggregateWindow(r, now, now.Sub(start))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		result.Aggregations = costModel.AggregationsMonthToDate(agg, now, loc, runRate)
+	}
+	w.Write(wrapData(result, nil))
+}
+
+// CostDiff compares the aggregated cost of a window with that of an earlier window of the same length, by default
+// the one right before it, e.g. this week against last week.
+func (a *Accesses) CostDiff(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	window := r.URL.Query().Get("window")
+	offset := r.URL.Query().Get("offset")
+	compareOffset := r.URL.Query().Get("compareOffset")
+
+	if r.URL.Query().Get("aggregation") == "" {
+		writeError(w, badRequestError{fmt.Errorf("Missing aggregation")})
+		return
+	}
+	if window == "" {
+		window = "7d"
+	}
+	d, err := parseWindow(window)
+	if err != nil {
+		writeError(w, badRequestError{err})
+		return
+	}
+	endTime := time.Now()
+	if offset != "" {
+		o, err := parseWindow(offset)
+		if err != nil {
+			writeError(w, badRequestError{err})
+			return
+		}
+		endTime = endTime.Add(-1 * o)
+	}
+	compare := d
+	if compareOffset != "" {
+		compare, err = parseWindow(compareOffset)
+		if err != nil {
+			writeError(w, badRequestError{err})
+			return
+		}
+	}
+	previousEndTime := endTime.Add(-1 * compare)
+
+	current, err := a.aggregateWindow(r, endTime, d)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	previous, err := a.aggregateWindow(r, previousEndTime, d)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	result := &costModel.CostComparison{
+		Start:         endTime.Add(-1 * d).UTC().Format(time.RFC3339),
+		End:           endTime.UTC().Format(time.RFC3339),
+		PreviousStart: previousEndTime.Add(-1 * d).UTC().Format(time.RFC3339),
+		PreviousEnd:   previousEndTime.UTC().Format(time.RFC3339),
+		Diffs:         costModel.DiffAggregations(current, previous),
+	}
+	w.Write(wrapData(result, nil))
+}
+
+// Forecast forecasts the daily cost of each aggregation over the next days, from the trend and weekly seasonality
+// of its daily cost over the window of complete days before today.
+func (a *Accesses) Forecast(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	window := r.URL.Query().Get("window")
+	days := r.URL.Query().Get("days")
+
+	if r.URL.Query().Get("aggregation") == "" {
+		writeError(w, badRequestError{fmt.Errorf("Missing aggregation")})
+		return
+	}
+	if window == "" {
+		window = "28d"
+	}
+	d, err := parseWindow(window)
+	if err != nil {
+		writeError(w, badRequestError{err})
+		return
+	}
+	forecastDays := 30
+	if days != "" {
+		forecastDays, err = strconv.Atoi(days)
+		if err != nil {
+			writeError(w, badRequestError{err})
+			return
+		}
+		if forecastDays < 1 || forecastDays > 365 {
+			writeError(w, badRequestError{fmt.Errorf("Invalid days %d, expected a value between 1 and 365", forecastDays)})
+			return
+		}
+	}
+
+	endTime := time.Now().UTC().Truncate(24 * time.Hour)
+	agg, err := a.aggregateWindow(r, endTime, d)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	forecasts, err := costModel.ForecastAggregations(agg, endTime, forecastDays)
+	w.Write(wrapData(forecasts, err))
+}
+
+// aggregateWindow aggregates hourly cost data over the window ending at endTime, according to the aggregation
+// parameters of the request.
+func (a *Accesses) aggregateWindow(r *http.Request, endTime time.Time, window time.Duration) (map[string]*costModel.Aggregation, error) {
+	aggregation := r.URL.Query().Get("aggregation")
+	namespace := r.URL.Query().Get("namespace")
+	aggregationSubField := r.URL.Query().Get("aggregationSubfield")
+
+	strategy, err := allocationStrategy(r)
+	if err != nil {
+		return nil, err
+	}
+	layout := "2006-01-02T15:04:05.000Z"
+	start := endTime.Add(-1 * window).UTC().Format(layout)
+	end := endTime.UTC().Format(layout)
+	data, err := a.Model.ComputeCostDataRange(a.PrometheusClient, a.KubeClientSet, a.Cloud, start, end, "1h", namespace)
+	if err != nil {
+		return nil, err
+	}
+	if strategy != nil {
+		costModel.ApplyAllocationStrategy(data, strategy)
+	}
+	c, err := a.Cloud.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	discount, err := strconv.ParseFloat(c.Discount[:len(c.Discount)-1], 64)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := aggregationOptions(r, c, discount*0.01, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DedicatedNodes && namespace == "" {
+		opts.DedicatedNodeCosts, err = a.Model.ComputeDedicatedNodesRange(a.PrometheusClient, a.Cloud, start, end, "1h")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return costModel.AggregateCostModel(data, aggregation, aggregationSubField, opts), nil
+}
+
+// parseWindow parses a duration which, unlike time.ParseDuration, may also be given in days, e.g. "7d".
+func parseWindow(window string) (time.Duration, error) {
+	if strings.HasSuffix(window, "d") {
+		days, err := strconv.ParseInt(strings.TrimSuffix(window, "d"), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(window)
+}
+
 func (a *Accesses) AggregateCostModel(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -235,6 +533,11 @@ func (a *Accesses) AggregateCostModel(w http.ResponseWriter, r *http.Request, ps
 		w.Write(wrapData(nil, err))
 		return
 	}
+	strategy, err := allocationStrategy(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	startTime := endTime.Add(-1 * d)
 	layout := "2006-01-02T15:04:05.000Z"
 	start := startTime.Format(layout)
@@ -244,6 +547,9 @@ func (a *Accesses) AggregateCostModel(w http.ResponseWriter, r *http.Request, ps
 		w.Write(wrapData(nil, err))
 		return
 	}
+	if strategy != nil {
+		costModel.ApplyAllocationStrategy(data, strategy)
+	}
 	c, err := a.Cloud.GetConfig()
 	if err != nil {
 		w.Write(wrapData(nil, err))
@@ -253,11 +559,83 @@ func (a *Accesses) AggregateCostModel(w http.ResponseWriter, r *http.Request, ps
 		w.Write(wrapData(nil, err))
 	}
 	if aggregation != "" {
-		agg := costModel.AggregateCostModel(data, discount*0.01, aggregation, aggregationSubField)
+		opts, err := aggregationOptions(r, c, discount*0.01, namespace)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if opts.DedicatedNodes && namespace == "" {
+			opts.DedicatedNodeCosts, err = a.Model.ComputeDedicatedNodesRange(a.PrometheusClient, a.Cloud, start, end, "1h")
+			if err != nil {
+				w.Write(wrapData(nil, err))
+				return
+			}
+		}
+		agg := costModel.AggregateCostModel(data, aggregation, aggregationSubField, opts)
+		if r.URL.Query().Get("timeseries") == "true" {
+			if err := costModel.ComputeTimeSeries(agg, r.URL.Query().Get("resolution")); err != nil {
+				writeError(w, badRequestError{err})
+				return
+			}
+		}
 		w.Write(wrapData(agg, nil))
 	}
 }
 
+// RequestRecommendations recommends container requests from a percentile of their usage over a window, and
+// optionally returns patches applying them to each controller.
+func (a *Accesses) RequestRecommendations(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = "7d"
+	}
+	cpuPercentile, err := percentileParam(r, "cpuPercentile")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ramPercentile, err := percentileParam(r, "ramPercentile")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	patch := r.URL.Query().Get("patch")
+
+	c, err := a.Cloud.GetConfig()
+	if err != nil {
+		w.Write(wrapData(nil, err))
+		return
+	}
+	discount, err := strconv.ParseFloat(c.Discount[:len(c.Discount)-1], 64)
+	if err != nil {
+		w.Write(wrapData(nil, err))
+		return
+	}
+
+	data, err := a.Model.ComputeRequestRecommendations(a.PrometheusClient, a.Cloud, window, cpuPercentile, ramPercentile, discount*0.01, patch)
+	w.Write(wrapData(data, err))
+}
+
+// percentileParam parses a percentile between 0 and 1 from the query, defaulting to 0.95. An invalid percentile is
+// returned as a badRequestError.
+func percentileParam(r *http.Request, name string) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0.95, nil
+	}
+	p, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, badRequestError{err}
+	}
+	if p < 0 || p > 1 {
+		return 0, badRequestError{fmt.Errorf("Invalid %s %s, expected a value between 0 and 1", name, value)}
+	}
+	return p, nil
+}
+
 func (a *Accesses) CostDataModelRange(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -270,10 +648,18 @@ func (a *Accesses) CostDataModelRange(w http.ResponseWriter, r *http.Request, ps
 	aggregation := r.URL.Query().Get("aggregation")
 	aggregationSubField := r.URL.Query().Get("aggregationSubfield")
 
+	strategy, err := allocationStrategy(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	data, err := a.Model.ComputeCostDataRange(a.PrometheusClient, a.KubeClientSet, a.Cloud, start, end, window, namespace)
 	if err != nil {
 		w.Write(wrapData(nil, err))
 	}
+	if strategy != nil {
+		costModel.ApplyAllocationStrategy(data, strategy)
+	}
 	if aggregation != "" {
 		c, err := a.Cloud.GetConfig()
 		if err != nil {
@@ -283,7 +669,19 @@ func (a *Accesses) CostDataModelRange(w http.ResponseWriter, r *http.Request, ps
 		if err != nil {
 			w.Write(wrapData(nil, err))
 		}
-		agg := costModel.AggregateCostModel(data, discount, aggregation, aggregationSubField)
+		opts, err := aggregationOptions(r, c, discount*0.01, namespace)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if opts.DedicatedNodes && namespace == "" {
+			opts.DedicatedNodeCosts, err = a.Model.ComputeDedicatedNodesRange(a.PrometheusClient, a.Cloud, start, end, window)
+			if err != nil {
+				w.Write(wrapData(nil, err))
+				return
+			}
+		}
+		agg := costModel.AggregateCostModel(data, aggregation, aggregationSubField, opts)
 		w.Write(wrapData(agg, nil))
 	} else {
 		if fields != "" {
@@ -466,19 +864,250 @@ func (p *Accesses) ContainerUptimes(w http.ResponseWriter, _ *http.Request, _ ht
 	w.Write(wrapData(res, err))
 }
 
+// detectAnomalies evaluates, once a day, the cost of every namespace and of every value of the labels configured in
+// anomalyDetectionLabels on the previous day against its rolling baseline.
+func (a *Accesses) detectAnomalies() {
+	go func() {
+		var lastEvaluated time.Time
+		for {
+			end := time.Now().UTC().Truncate(24 * time.Hour)
+			if end.After(lastEvaluated) {
+				err := a.evaluateAnomalies(end)
+				if err != nil {
+					klog.V(1).Infof("Error detecting anomalies: %s", err.Error())
+				} else {
+					lastEvaluated = end
+				}
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+func (a *Accesses) evaluateAnomalies(end time.Time) error {
+	c, err := a.Cloud.GetConfig()
+	if err != nil {
+		return err
+	}
+	discount, err := strconv.ParseFloat(c.Discount[:len(c.Discount)-1], 64)
+	if err != nil {
+		return err
+	}
+	layout := "2006-01-02T15:04:05.000Z"
+	start := end.Add(-1 * (costModel.AnomalyBaselineDays + 1) * 24 * time.Hour)
+	data, err := a.Model.ComputeCostDataRange(a.PrometheusClient, a.KubeClientSet, a.Cloud, start.Format(layout), end.Format(layout), "1h", "")
+	if err != nil {
+		return err
+	}
+
+	aggregators := []string{"namespace"}
+	for _, label := range strings.Split(c.AnomalyDetectionLabels, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			aggregators = append(aggregators, "label:"+label)
+		}
+	}
+	opts := &costModel.AggregationOptions{
+		Discount: discount * 0.01,
+		Pricing:  costModel.NewPricingRules(c),
+	}
+	for _, aggregator := range aggregators {
+		agg := costModel.AggregateCostModel(data, aggregator, "", opts)
+		anomalies, err := costModel.DetectAnomalies(agg, aggregator, end)
+		if err != nil {
+			return err
+		}
+		if len(anomalies) > 0 {
+			klog.V(1).Infof("Detected %d cost anomalies by %s", len(anomalies), aggregator)
+		}
+		if err := a.Anomalies.Add(anomalies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAnomalies lists the cost anomalies detected over the window, optionally for a single aggregator, such as
+// "namespace" or "label:team", and key.
+func (a *Accesses) GetAnomalies(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	window := r.URL.Query().Get("window")
+	aggregator := r.URL.Query().Get("aggregator")
+	key := r.URL.Query().Get("key")
+
+	if window == "" {
+		window = "30d"
+	}
+	d, err := parseWindow(window)
+	if err != nil {
+		writeError(w, badRequestError{err})
+		return
+	}
+	since := time.Now().UTC().Truncate(24 * time.Hour).Add(-1 * d)
+	w.Write(wrapData(a.Anomalies.List(since, aggregator, key), nil))
+}
+
+// GetBudgets lists the budgets with their spend as of their last evaluation.
+func (a *Accesses) GetBudgets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Write(wrapData(a.Budgets.List(), nil))
+}
+
+// UpdateBudget creates the budget in the request body, or replaces the one with the same aggregator and key.
+func (a *Accesses) UpdateBudget(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	budget := &costModel.Budget{}
+	err := json.NewDecoder(r.Body).Decode(budget)
+	if err != nil {
+		writeError(w, badRequestError{err})
+		return
+	}
+	err = budget.Validate()
+	if err != nil {
+		writeError(w, badRequestError{err})
+		return
+	}
+	err = a.Budgets.Set(budget)
+	if err != nil {
+		w.Write(wrapData(nil, err))
+		return
+	}
+	w.Write(wrapData(budget, nil))
+}
+
+// DeleteBudget deletes the budget of the aggregator and key given as parameters.
+func (a *Accesses) DeleteBudget(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	aggregator := r.URL.Query().Get("aggregator")
+	key := r.URL.Query().Get("key")
+
+	err := a.Budgets.Delete(aggregator, key)
+	w.Write(wrapData(nil, err))
+}
+
+// evaluateBudgets compares the month-to-date and projected spend of every budget, in UTC months, with its
+// thresholds, and posts an alert to the webhooks for each threshold newly crossed.
+func (a *Accesses) evaluateBudgets(now time.Time) error {
+	aggregators := a.Budgets.Aggregators()
+	if len(aggregators) == 0 {
+		return nil
+	}
+	c, err := a.Cloud.GetConfig()
+	if err != nil {
+		return err
+	}
+	discount, err := strconv.ParseFloat(c.Discount[:len(c.Discount)-1], 64)
+	if err != nil {
+		return err
+	}
+	var webhookURLs []string
+	for _, url := range strings.Split(c.BudgetWebhookURLs, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			webhookURLs = append(webhookURLs, url)
+		}
+	}
+
+	// Each hourly step of cost data covers the hour before it, so the first step is an hour into the month.
+	monthStart, _ := costModel.MonthBounds(now, time.UTC)
+	start := monthStart.Add(time.Hour)
+	data := map[string]*costModel.CostData{}
+	if start.Before(now) {
+		layout := "2006-01-02T15:04:05.000Z"
+		data, err = a.Model.ComputeCostDataRange(a.PrometheusClient, a.KubeClientSet, a.Cloud, start.UTC().Format(layout), now.UTC().Format(layout), "1h", "")
+		if err != nil {
+			return err
+		}
+	}
+	opts := &costModel.AggregationOptions{
+		Discount: discount * 0.01,
+		Pricing:  costModel.NewPricingRules(c),
+	}
+	for _, aggregator := range aggregators {
+		agg := costModel.AggregateCostModel(data, aggregator, "", opts)
+		alerts, err := a.Budgets.EvaluateAggregations(aggregator, agg, webhookURLs, now)
+		if err != nil {
+			klog.V(1).Infof("Error saving the budgets' alerts: %s", err.Error())
+		}
+		for _, alert := range alerts {
+			klog.V(1).Infof("Budget alert: %s", alert.Message)
+			for _, url := range alert.WebhookURLs() {
+				err := costModel.PostBudgetAlert(url, alert)
+				if err != nil {
+					klog.V(1).Infof("Error posting budget alert to %s: %s", url, err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func getKeyFromLabelStrings(labels ...string) string {
+	return strings.Join(labels, ",")
+}
+
+func getLabelStringsFromKey(key string) []string {
+	return strings.Split(key, ",")
+}
+
+// recordAggregatedCosts records the hourly cost of every namespace and the monthly cost of every value of the labels
+// in CostMetricsLabels, marking the gauges set as seen.
+func (a *Accesses) recordAggregatedCosts(data map[string]*costModel.CostData, c *costAnalyzerCloud.CustomPricing, namespaceSeen map[string]bool, labelSeen map[string]bool) error {
+	discount, err := strconv.ParseFloat(c.Discount[:len(c.Discount)-1], 64)
+	if err != nil {
+		return err
+	}
+	opts := &costModel.AggregationOptions{
+		Discount: discount * 0.01,
+		Pricing:  costModel.NewPricingRules(c),
+	}
+	for namespace, agg := range costModel.AggregateCostModel(data, "namespace", "", opts) {
+		a.NamespaceHourlyCostRecorder.WithLabelValues(namespace).Set(agg.TotalCost)
+		namespaceSeen[namespace] = true
+	}
+	for _, label := range strings.Split(c.CostMetricsLabels, ",") {
+		label = strings.TrimSpace(label)
+		if label == "" {
+			continue
+		}
+		for value, agg := range costModel.AggregateCostModel(data, "label:"+label, "", opts) {
+			if value == costModel.UnallocatedKey {
+				continue
+			}
+			a.LabelMonthlyCostRecorder.WithLabelValues(label, value).Set(agg.TotalCost * 730)
+			labelSeen[getKeyFromLabelStrings(label, value)] = true
+		}
+	}
+	return nil
+}
+
+// recordBudgetUtilization records the utilization of every budget evaluated, marking the gauges set as seen.
+func (a *Accesses) recordBudgetUtilization(budgetSeen map[string]bool) {
+	for _, status := range a.Budgets.List() {
+		if status.MonthToDate == nil {
+			continue
+		}
+		a.BudgetUtilizationRecorder.WithLabelValues(status.Aggregator, status.Key).Set(status.Utilization)
+		a.BudgetProjectedUtilRecorder.WithLabelValues(status.Aggregator, status.Key).Set(status.ProjectedUtilization)
+		budgetSeen[getKeyFromLabelStrings(status.Aggregator, status.Key)] = true
+	}
+}
+
 func (a *Accesses) recordPrices() {
 	go func() {
 		containerSeen := make(map[string]bool)
 		nodeSeen := make(map[string]bool)
 		pvSeen := make(map[string]bool)
 		pvcSeen := make(map[string]bool)
-
-		getKeyFromLabelStrings := func(labels ...string) string {
-			return strings.Join(labels, ",")
-		}
-		getLabelStringsFromKey := func(key string) []string {
-			return strings.Split(key, ",")
-		}
+		namespaceSeen := make(map[string]bool)
+		labelSeen := make(map[string]bool)
+		budgetSeen := make(map[string]bool)
+		var lastBudgetEvaluation time.Time
 
 		for {
 			klog.V(4).Info("Recording prices...")
@@ -594,6 +1223,26 @@ func (a *Accesses) recordPrices() {
 					a.ContainerUptimeRecorder.WithLabelValues(container.Namespace, container.PodName, container.ContainerName).Set(uptime)
 				}
 			}
+			// Record the current cost of namespaces and label values, and the utilization of budgets, so that alert
+			// rules need not join the per container metrics above.
+			c, err := a.Cloud.GetConfig()
+			if err == nil {
+				err = a.recordAggregatedCosts(data, c, namespaceSeen, labelSeen)
+			}
+			if err != nil {
+				klog.V(1).Infof("Error recording aggregated costs: %s", err.Error())
+			}
+			// Budgets are evaluated over the month to date, so less often than prices are recorded.
+			if time.Since(lastBudgetEvaluation) >= budgetEvaluationInterval {
+				err := a.evaluateBudgets(time.Now())
+				if err != nil {
+					klog.V(1).Infof("Error evaluating budgets: %s", err.Error())
+				} else {
+					lastBudgetEvaluation = time.Now()
+				}
+			}
+			a.recordBudgetUtilization(budgetSeen)
+
 			for labelString, seen := range nodeSeen {
 				if !seen {
 					labels := getLabelStringsFromKey(labelString)
@@ -632,6 +1281,31 @@ func (a *Accesses) recordPrices() {
 				}
 				pvcSeen[labelString] = false
 			}
+			for labelString, seen := range namespaceSeen {
+				if !seen {
+					a.NamespaceHourlyCostRecorder.DeleteLabelValues(labelString)
+					delete(namespaceSeen, labelString)
+				}
+				namespaceSeen[labelString] = false
+			}
+			for labelString, seen := range labelSeen {
+				if !seen {
+					labels := getLabelStringsFromKey(labelString)
+					a.LabelMonthlyCostRecorder.DeleteLabelValues(labels...)
+					delete(labelSeen, labelString)
+				}
+				labelSeen[labelString] = false
+			}
+			for labelString, seen := range budgetSeen {
+				if !seen {
+					labels := getLabelStringsFromKey(labelString)
+					a.BudgetUtilizationRecorder.DeleteLabelValues(labels...)
+					a.BudgetProjectedUtilRecorder.DeleteLabelValues(labels...)
+					delete(budgetSeen, labelString)
+				}
+				budgetSeen[labelString] = false
+			}
+
 			time.Sleep(time.Minute)
 		}
 	}()
@@ -754,6 +1428,23 @@ func main() {
 		Help: "kubecost_network_internet_egress_cost Total cost per GB of internet egress.",
 	})
 
+	NamespaceHourlyCostRecorder := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubecost_namespace_hourly_cost",
+		Help: "kubecost_namespace_hourly_cost Current hourly cost of the containers in a namespace",
+	}, []string{"namespace"})
+	LabelMonthlyCostRecorder := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubecost_label_monthly_cost",
+		Help: "kubecost_label_monthly_cost Monthly cost, at the current hourly cost, of the containers with a label value",
+	}, []string{"label", "value"})
+	BudgetUtilizationRecorder := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubecost_budget_utilization_ratio",
+		Help: "kubecost_budget_utilization_ratio Month-to-date spend of a budget as a fraction of its monthly amount",
+	}, []string{"aggregator", "key"})
+	BudgetProjectedUtilRecorder := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubecost_budget_projected_utilization_ratio",
+		Help: "kubecost_budget_projected_utilization_ratio Spend of a budget projected to the end of the month as a fraction of its monthly amount",
+	}, []string{"aggregator", "key"})
+
 	prometheus.MustRegister(cpuGv)
 	prometheus.MustRegister(ramGv)
 	prometheus.MustRegister(gpuGv)
@@ -764,6 +1455,7 @@ func main() {
 	prometheus.MustRegister(ContainerUptimeRecorder)
 	prometheus.MustRegister(PVAllocation)
 	prometheus.MustRegister(NetworkZoneEgressRecorder, NetworkRegionEgressRecorder, NetworkInternetEgressRecorder)
+	prometheus.MustRegister(NamespaceHourlyCostRecorder, LabelMonthlyCostRecorder, BudgetUtilizationRecorder, BudgetProjectedUtilRecorder)
 	prometheus.MustRegister(costModel.ServiceCollector{
 		KubeClientSet: kubeClientset,
 	})
@@ -788,9 +1480,34 @@ func main() {
 		NetworkRegionEgressRecorder:   NetworkRegionEgressRecorder,
 		NetworkInternetEgressRecorder: NetworkInternetEgressRecorder,
 		PersistentVolumePriceRecorder: pvGv,
+		NamespaceHourlyCostRecorder:   NamespaceHourlyCostRecorder,
+		LabelMonthlyCostRecorder:      LabelMonthlyCostRecorder,
+		BudgetUtilizationRecorder:     BudgetUtilizationRecorder,
+		BudgetProjectedUtilRecorder:   BudgetProjectedUtilRecorder,
 		Model:                         costModel.NewCostModel(kubeClientset),
 	}
 
+	if c, err := cloudProvider.GetConfig(); err == nil && c.AllocationStrategy != "" {
+		strategy, err := costModel.NewAllocationStrategy(c.AllocationStrategy, c.AllocationWeight)
+		if err != nil {
+			klog.Fatalf("Failed to load the allocation strategy: %s", err.Error())
+		}
+		a.Model.AllocationStrategy = strategy
+	}
+
+	configPath := os.Getenv("CONFIG_PATH")
+	if configPath == "" {
+		configPath = "/models/"
+	}
+	a.Budgets, err = costModel.NewBudgetStore(configPath + "budgets.json")
+	if err != nil {
+		klog.Fatalf("Failed to load budgets from %s: %s", configPath, err.Error())
+	}
+	a.Anomalies, err = costModel.NewAnomalyLog(configPath + "anomalies.json")
+	if err != nil {
+		klog.Fatalf("Failed to load anomalies from %s: %s", configPath, err.Error())
+	}
+
 	remoteEnabled := os.Getenv(remoteEnabled)
 	if remoteEnabled == "true" {
 		info, err := cloudProvider.ClusterInfo()
@@ -810,6 +1527,7 @@ func main() {
 	}
 
 	a.recordPrices()
+	a.detectAnomalies()
 
 	router := httprouter.New()
 	router.GET("/costDataModel", a.CostDataModel)
@@ -831,6 +1549,14 @@ func main() {
 	router.GET("/clusterInfo", a.ClusterInfo)
 	router.GET("/containerUptimes", a.ContainerUptimes)
 	router.GET("/aggregatedCostModel", a.AggregateCostModel)
+	router.GET("/recommendations/requests", a.RequestRecommendations)
+	router.GET("/costs/monthToDate", a.MonthToDateCosts)
+	router.GET("/costDiff", a.CostDiff)
+	router.GET("/forecast", a.Forecast)
+	router.GET("/anomalies", a.GetAnomalies)
+	router.GET("/budgets", a.GetBudgets)
+	router.POST("/updateBudget", a.UpdateBudget)
+	router.POST("/deleteBudget", a.DeleteBudget)
 
 	rootMux := http.NewServeMux()
 	rootMux.Handle("/", router)
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	costAnalyzerCloud "github.com/kubecost/cost-model/cloud"
+	costModel "github.com/kubecost/cost-model/costmodel"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gaugeValues returns the value of every gauge of the vector, keyed by its label values joined by ",".
+func gaugeValues(t *testing.T, gv *prometheus.GaugeVec, labelNames ...string) map[string]float64 {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(gv)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Error gathering metrics: %s", err.Error())
+	}
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if len(labels) != len(labelNames) {
+				t.Errorf("%s has labels %v, expected %v", family.GetName(), labels, labelNames)
+			}
+			var key []string
+			for _, name := range labelNames {
+				key = append(key, labels[name])
+			}
+			values[strings.Join(key, ",")] = m.GetGauge().GetValue()
+		}
+	}
+	return values
+}
+
+func TestRecordAggregatedCosts(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/cost_data.json")
+	if err != nil {
+		t.Fatalf("Error reading the fixture: %s", err.Error())
+	}
+	var data map[string]*costModel.CostData
+	if err := json.Unmarshal(b, &data); err != nil {
+		t.Fatalf("Error parsing the fixture: %s", err.Error())
+	}
+
+	dir, err := ioutil.TempDir("", "budgets")
+	if err != nil {
+		t.Fatalf("Error creating a temporary directory: %s", err.Error())
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	budgets, err := costModel.NewBudgetStore(filepath.Join(dir, "budgets.json"))
+	if err != nil {
+		t.Fatalf("Error creating the budget store: %s", err.Error())
+	}
+	for _, budget := range []*costModel.Budget{
+		{Aggregator: "namespace", Key: "ns1", MonthlyAmount: 100},
+		{Aggregator: "namespace", Key: "unevaluated", MonthlyAmount: 100},
+	} {
+		if err := budgets.Set(budget); err != nil {
+			t.Fatalf("Error setting a budget: %s", err.Error())
+		}
+	}
+	now := time.Date(2019, 9, 16, 0, 0, 0, 0, time.UTC)
+	costs := map[string]*costModel.MonthToDate{
+		"ns1": {Cost: 50, ProjectedCost: 90},
+	}
+	budgets.Evaluate("namespace", costs, nil, now)
+	budgets.Evaluate("label:team", nil, nil, now)
+
+	a := &Accesses{
+		NamespaceHourlyCostRecorder: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kubecost_namespace_hourly_cost",
+		}, []string{"namespace"}),
+		LabelMonthlyCostRecorder: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kubecost_label_monthly_cost",
+		}, []string{"label", "value"}),
+		BudgetUtilizationRecorder: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kubecost_budget_utilization_ratio",
+		}, []string{"aggregator", "key"}),
+		BudgetProjectedUtilRecorder: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "kubecost_budget_projected_utilization_ratio",
+		}, []string{"aggregator", "key"}),
+		Budgets: budgets,
+	}
+	c := &costAnalyzerCloud.CustomPricing{Discount: "10%", CostMetricsLabels: "team, missing"}
+	namespaceSeen := make(map[string]bool)
+	labelSeen := make(map[string]bool)
+	budgetSeen := make(map[string]bool)
+	if err := a.recordAggregatedCosts(data, c, namespaceSeen, labelSeen); err != nil {
+		t.Fatalf("Error recording aggregated costs: %s", err.Error())
+	}
+	a.recordBudgetUtilization(budgetSeen)
+
+	// ns1 is allocated a vCPU at 0.5 and a GB of RAM at 0.25, ns2 twice as much plus half a vCPU, all discounted by 10%.
+	cases := []struct {
+		name     string
+		values   map[string]float64
+		expected map[string]float64
+		seen     map[string]bool
+	}{
+		{
+			name:     "namespace hourly cost",
+			values:   gaugeValues(t, a.NamespaceHourlyCostRecorder, "namespace"),
+			expected: map[string]float64{"ns1": 0.675, "ns2": 1.575},
+			seen:     namespaceSeen,
+		},
+		{
+			// The unlabeled sidecar is left out.
+			name:     "label monthly cost",
+			values:   gaugeValues(t, a.LabelMonthlyCostRecorder, "label", "value"),
+			expected: map[string]float64{"team,a": 0.675 * 730, "team,b": 1.35 * 730},
+			seen:     labelSeen,
+		},
+		{
+			name:     "budget utilization",
+			values:   gaugeValues(t, a.BudgetUtilizationRecorder, "aggregator", "key"),
+			expected: map[string]float64{"namespace,ns1": 0.5, "namespace,unevaluated": 0},
+			seen:     budgetSeen,
+		},
+		{
+			name:     "budget projected utilization",
+			values:   gaugeValues(t, a.BudgetProjectedUtilRecorder, "aggregator", "key"),
+			expected: map[string]float64{"namespace,ns1": 0.9, "namespace,unevaluated": 0},
+			seen:     budgetSeen,
+		},
+	}
+	for _, c := range cases {
+		if len(c.values) != len(c.expected) {
+			t.Errorf("%s: got gauges %v, expected %v", c.name, c.values, c.expected)
+		}
+		for key, expected := range c.expected {
+			if v, ok := c.values[key]; !ok || math.Abs(v-expected) > 1e-9 {
+				t.Errorf("%s: gauge %s is %f, expected %f", c.name, key, v, expected)
+			}
+			if !c.seen[key] {
+				t.Errorf("%s: gauge %s is not marked as seen", c.name, key)
+			}
+		}
+	}
+}
+
+func TestBadRequests(t *testing.T) {
+	a := &Accesses{}
+	cases := []struct {
+		name    string
+		handler httprouter.Handle
+		method  string
+		url     string
+		body    string
+	}{
+		{"allocation", a.CostDataModel, "GET", "/costDataModel?timeWindow=1d&allocation=min", ""},
+		{"aggregated allocation", a.AggregateCostModel, "GET", "/aggregatedCostModel?window=1d&aggregation=namespace&allocation=weighted&allocationWeight=2", ""},
+		{"range allocation", a.CostDataModelRange, "GET", "/costDataModelRange?start=2019-09-01T00:00:00.000Z&end=2019-09-02T00:00:00.000Z&window=1h&allocation=min", ""},
+		{"diff window", a.CostDiff, "GET", "/costDiff?aggregation=namespace&window=week", ""},
+		{"diff without aggregation", a.CostDiff, "GET", "/costDiff", ""},
+		{"forecast days", a.Forecast, "GET", "/forecast?aggregation=namespace&days=0", ""},
+		{"forecast days not a number", a.Forecast, "GET", "/forecast?aggregation=namespace&days=month", ""},
+		{"percentile", a.RequestRecommendations, "GET", "/requestRecommendations?cpuPercentile=95", ""},
+		{"timezone", a.MonthToDateCosts, "GET", "/monthToDateCosts?timezone=Moon/Base", ""},
+		{"run-rate window", a.MonthToDateCosts, "GET", "/monthToDateCosts?runRateWindow=day", ""},
+		{"anomalies window", a.GetAnomalies, "GET", "/anomalies?window=month", ""},
+		{"budget body", a.UpdateBudget, "PATCH", "/budgets", "{"},
+		{"budget amount", a.UpdateBudget, "PATCH", "/budgets", `{"aggregator": "namespace", "key": "ns1", "monthlyAmount": -1}`},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		c.handler(w, httptest.NewRequest(c.method, c.url, strings.NewReader(c.body)), nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, expected %d", c.name, w.Code, http.StatusBadRequest)
+		}
+	}
+}